@@ -9,66 +9,100 @@ import (
 )
 
 const (
-	AccessTokenExpiration  = 7 * 24 * time.Hour  // 7 days
+	AccessTokenExpiration  = 15 * time.Minute    // 15 minutes
 	RefreshTokenExpiration = 30 * 24 * time.Hour // 30 days
 )
 
+// Token types, stored in the "type" claim so a refresh token can't be used as
+// an access token or the other way around
+const (
+	TokenTypeAccess            = "access"
+	TokenTypeRefresh           = "refresh"
+	TokenTypeEmailVerification = "email_verification"
+)
+
 var (
 	ErrInvalidToken = errors.New("invalid token")
 	ErrExpiredToken = errors.New("token has expired")
 )
 
 type Claims struct {
-	UserID string `json:"user_id"`
+	UserID     string `json:"user_id"`
+	Role       string `json:"role,omitempty"`
+	Type       string `json:"type"`
+	Family     string `json:"family,omitempty"`     // Refresh tokens only, shared by every token rotated from the same login
+	Unverified bool   `json:"unverified,omitempty"` // Access tokens only, set until the user verifies their email
 	jwt.RegisteredClaims
 }
 
 type TokenPair struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // Seconds until the access token expires
 }
 
-func GenerateTokenPair(userID string, secretKey string) (*TokenPair, error) {
+// GenerateTokenPair signs an access token and a refresh token for a user with
+// the given role. The access token of an unverified user is marked so write
+// routes reject it. The refresh token joins the given family, or starts a new
+// one when it is empty. The claims of the refresh token are returned so its jti
+// can be stored.
+func GenerateTokenPair(userID, role string, verified bool, family string, keys *KeySet) (*TokenPair, *Claims, error) {
 	// Generate access token
-	accessToken, err := generateToken(userID, AccessTokenExpiration, secretKey)
+	accessClaims := newClaims(userID, role, TokenTypeAccess, AccessTokenExpiration)
+	accessClaims.Unverified = !verified
+	accessToken, err := keys.Sign(accessClaims)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Generate refresh token
-	refreshToken, err := generateToken(userID, RefreshTokenExpiration, secretKey)
+	refreshClaims := newClaims(userID, role, TokenTypeRefresh, RefreshTokenExpiration)
+	refreshClaims.Family = family
+	if refreshClaims.Family == "" {
+		refreshClaims.Family = refreshClaims.ID
+	}
+	refreshToken, err := keys.Sign(refreshClaims)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	return &TokenPair{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-	}, nil
+		ExpiresIn:    int64(AccessTokenExpiration.Seconds()),
+	}, refreshClaims, nil
+}
+
+// GenerateToken signs a single token of the given type for a user, such as the
+// email verification tokens. The claims are returned so its jti can be stored.
+func GenerateToken(userID, tokenType string, duration time.Duration, keys *KeySet) (string, *Claims, error) {
+	claims := newClaims(userID, "", tokenType, duration)
+	token, err := keys.Sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
 }
 
-func generateToken(userID string, duration time.Duration, secretKey string) (string, error) {
-	claims := &Claims{
+func newClaims(userID, role, tokenType string, duration time.Duration) *Claims {
+	now := time.Now()
+	return &Claims{
 		UserID: userID,
+		Role:   role,
+		Type:   tokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 			ID:        uuid.New().String(),
 		},
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secretKey))
 }
 
-func ValidateToken(tokenString string, secretKey string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return []byte(secretKey), nil
-	})
+// ValidateToken checks the signature and expiration of a token and that it is
+// of the expected type
+func ValidateToken(tokenString string, keys *KeySet, tokenType string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.keyFunc)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -77,7 +111,7 @@ func ValidateToken(tokenString string, secretKey string) (*Claims, error) {
 		return nil, ErrInvalidToken
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && claims.Type == tokenType {
 		return claims, nil
 	}
 
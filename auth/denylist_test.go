@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRevokeUserDeniesTokensOfTheSameSecond(t *testing.T) {
+	denylist := NewDenylist(nil)
+	ctx := context.Background()
+	claims := func(issuedAt time.Time) *Claims {
+		return &Claims{UserID: "user", RegisteredClaims: jwt.RegisteredClaims{ID: issuedAt.String(), IssuedAt: jwt.NewNumericDate(issuedAt)}}
+	}
+
+	before := claims(time.Now())
+	if err := denylist.RevokeUser(ctx, "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !denylist.IsRevoked(ctx, before) {
+		t.Error("token issued before the revocation is still valid")
+	}
+	if after := claims(time.Now().Add(2 * time.Second)); denylist.IsRevoked(ctx, after) {
+		t.Error("token issued after the revocation is denied")
+	}
+	if other := (&Claims{UserID: "other", RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(time.Now())}}); denylist.IsRevoked(ctx, other) {
+		t.Error("token of another user is denied")
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	denylist := NewDenylist(nil)
+	ctx := context.Background()
+	revoked := &Claims{UserID: "user", RegisteredClaims: jwt.RegisteredClaims{ID: "revoked", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}}
+	other := &Claims{UserID: "user", RegisteredClaims: jwt.RegisteredClaims{ID: "other", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}}
+
+	if err := denylist.RevokeToken(ctx, revoked); err != nil {
+		t.Fatal(err)
+	}
+	if !denylist.IsRevoked(ctx, revoked) {
+		t.Error("revoked token is still valid")
+	}
+	if denylist.IsRevoked(ctx, other) {
+		t.Error("another token of the user is denied")
+	}
+}
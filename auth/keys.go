@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is an asymmetric key identified by its kid. It signs tokens from
+// NotBefore until the next key of the set becomes active, and keeps verifying
+// them until the last token it signed has expired.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	NotBefore  time.Time
+}
+
+// KeySet signs and verifies tokens. Tokens are signed with the newest active
+// asymmetric key and carry its kid; tokens without a kid are verified as legacy
+// HS256 tokens while a legacy secret is configured, until the first key has been
+// active for the lifetime of a refresh token. Without asymmetric keys the legacy
+// secret is also used for signing.
+type KeySet struct {
+	keys         []*SigningKey // Sorted by NotBefore
+	legacySecret []byte
+	now          func() time.Time
+}
+
+// NewKeySet creates a key set from asymmetric keys and a legacy HS256 secret,
+// either of which may be empty but not both
+func NewKeySet(keys []*SigningKey, legacySecret string) (*KeySet, error) {
+	if len(keys) == 0 && legacySecret == "" {
+		return nil, errors.New("at least one signing key or a legacy secret is required")
+	}
+
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("signing keys require a kid")
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate kid %q", key.ID)
+		}
+		seen[key.ID] = true
+	}
+
+	sorted := append([]*SigningKey(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].NotBefore.Before(sorted[j].NotBefore) })
+
+	return &KeySet{keys: sorted, legacySecret: []byte(legacySecret), now: time.Now}, nil
+}
+
+// keyManifestEntry is an entry of the JSON file listing the signing keys
+type keyManifestEntry struct {
+	ID         string    `json:"kid"`
+	PrivateKey string    `json:"private_key"` // PEM file, relative to the manifest
+	NotBefore  time.Time `json:"not_before"`
+}
+
+// LoadKeySet reads the signing keys listed in a JSON manifest such as
+//
+//	[{"kid": "2026-10", "private_key": "2026-10.pem", "not_before": "2026-10-01T00:00:00Z"}]
+//
+// RSA keys sign with RS256 and Ed25519 keys with EdDSA
+func LoadKeySet(manifestPath, legacySecret string) (*KeySet, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []keyManifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid key manifest: %w", err)
+	}
+
+	keys := make([]*SigningKey, 0, len(entries))
+	for _, entry := range entries {
+		path := entry.PrivateKey
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(manifestPath), path)
+		}
+		key, err := loadSigningKey(entry.ID, path, entry.NotBefore)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry.ID, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(keys, legacySecret)
+}
+
+func loadSigningKey(id, path string, notBefore time.Time) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{ID: id, NotBefore: notBefore}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.PrivateKey = jwt.SigningMethodRS256, private
+	case ed25519.PrivateKey:
+		key.Method, key.PrivateKey = jwt.SigningMethodEdDSA, private
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+// signingKey returns the newest key that is already active, nil when tokens are
+// signed with the legacy secret
+func (s *KeySet) signingKey() *SigningKey {
+	now := s.now()
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		if !s.keys[i].NotBefore.After(now) {
+			return s.keys[i]
+		}
+	}
+	return nil
+}
+
+// verifies reports whether a key can still have valid tokens: it is active or
+// was replaced less than the lifetime of a refresh token ago
+func (s *KeySet) verifies(index int) bool {
+	now := s.now()
+	if s.keys[index].NotBefore.After(now) {
+		return false
+	}
+	for _, next := range s.keys[index+1:] {
+		if !next.NotBefore.After(now) {
+			return now.Before(next.NotBefore.Add(RefreshTokenExpiration))
+		}
+	}
+	return true
+}
+
+// verifiesLegacy reports whether tokens signed with the legacy secret can still
+// be valid: no key is active yet or the first one became active less than the
+// lifetime of a refresh token ago
+func (s *KeySet) verifiesLegacy() bool {
+	if len(s.legacySecret) == 0 {
+		return false
+	}
+	if len(s.keys) == 0 {
+		return true
+	}
+	return s.now().Before(s.keys[0].NotBefore.Add(RefreshTokenExpiration))
+}
+
+// Sign signs claims with the current signing key
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key := s.signingKey()
+	if key == nil {
+		if len(s.legacySecret) == 0 {
+			return "", errors.New("no active signing key")
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.legacySecret)
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// keyFunc returns the key verifying a token, chosen by its kid and algorithm
+func (s *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok && s.verifiesLegacy() {
+			return s.legacySecret, nil
+		}
+		return nil, ErrInvalidToken
+	}
+
+	for i, key := range s.keys {
+		if key.ID != kid {
+			continue
+		}
+		if token.Method.Alg() != key.Method.Alg() || !s.verifies(i) {
+			return nil, ErrInvalidToken
+		}
+		return key.PrivateKey.Public(), nil
+	}
+	return nil, ErrInvalidToken
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys other services need to verify our tokens: the
+// keys that still verify tokens and the scheduled ones, so they are known
+// before they start signing
+func (s *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	now := s.now()
+	for i, key := range s.keys {
+		if !key.NotBefore.After(now) && !s.verifies(i) {
+			continue
+		}
+
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+		switch public := key.PrivateKey.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
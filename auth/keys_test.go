@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var rotation = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+func testKey(t *testing.T, id string, notBefore time.Time) *SigningKey {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, PrivateKey: private, NotBefore: notBefore}
+}
+
+// testKeySet returns a set with a key active from rotation, another one from a
+// month later and the legacy secret, at the time set in now
+func testKeySet(t *testing.T, now *time.Time) *KeySet {
+	t.Helper()
+	keys, err := NewKeySet([]*SigningKey{
+		testKey(t, "next", rotation.AddDate(0, 1, 0)),
+		testKey(t, "first", rotation),
+	}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.now = func() time.Time { return *now }
+	return keys
+}
+
+func TestSigningKey(t *testing.T) {
+	var now time.Time
+	keys := testKeySet(t, &now)
+	tests := []struct {
+		now  time.Time
+		want string
+	}{
+		{rotation.Add(-time.Second), ""},
+		{rotation, "first"},
+		{rotation.AddDate(0, 1, 0).Add(-time.Second), "first"},
+		{rotation.AddDate(0, 1, 0), "next"},
+	}
+
+	for _, tt := range tests {
+		now = tt.now
+		got := ""
+		if key := keys.signingKey(); key != nil {
+			got = key.ID
+		}
+		if got != tt.want {
+			t.Errorf("signingKey at %v = %q, want %q", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestVerifies(t *testing.T) {
+	var now time.Time
+	keys := testKeySet(t, &now)
+	replaced := rotation.AddDate(0, 1, 0)
+	tests := []struct {
+		now         time.Time
+		first, next bool
+	}{
+		{rotation.Add(-time.Second), false, false},
+		{rotation, true, false},
+		{replaced, true, true},
+		{replaced.Add(RefreshTokenExpiration - time.Second), true, true},
+		{replaced.Add(RefreshTokenExpiration), false, true},
+	}
+
+	for _, tt := range tests {
+		now = tt.now
+		if got := keys.verifies(0); got != tt.first {
+			t.Errorf("first key verifies at %v = %v, want %v", tt.now, got, tt.first)
+		}
+		if got := keys.verifies(1); got != tt.next {
+			t.Errorf("next key verifies at %v = %v, want %v", tt.now, got, tt.next)
+		}
+	}
+}
+
+func TestKeyFuncLegacyTokens(t *testing.T) {
+	var now time.Time
+	keys := testKeySet(t, &now)
+	legacy := jwt.New(jwt.SigningMethodHS256)
+	tests := []struct {
+		now  time.Time
+		want bool
+	}{
+		{rotation.Add(-time.Second), true},
+		{rotation.Add(RefreshTokenExpiration - time.Second), true},
+		{rotation.Add(RefreshTokenExpiration), false},
+	}
+
+	for _, tt := range tests {
+		now = tt.now
+		_, err := keys.keyFunc(legacy)
+		if got := err == nil; got != tt.want {
+			t.Errorf("legacy token accepted at %v = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+
+	withoutKeys, err := NewKeySet(nil, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := withoutKeys.keyFunc(legacy); err != nil {
+		t.Errorf("legacy token rejected without signing keys: %v", err)
+	}
+}
+
+func TestKeyFuncChecksKid(t *testing.T) {
+	now := rotation
+	keys := testKeySet(t, &now)
+	token := func(method jwt.SigningMethod, kid string) *jwt.Token {
+		token := jwt.New(method)
+		token.Header["kid"] = kid
+		return token
+	}
+
+	if _, err := keys.keyFunc(token(jwt.SigningMethodEdDSA, "first")); err != nil {
+		t.Errorf("token of the active key rejected: %v", err)
+	}
+	for name, token := range map[string]*jwt.Token{
+		"unknown kid":         token(jwt.SigningMethodEdDSA, "unknown"),
+		"scheduled key":       token(jwt.SigningMethodEdDSA, "next"),
+		"different algorithm": token(jwt.SigningMethodHS256, "first"),
+	} {
+		if _, err := keys.keyFunc(token); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package auth
+
+import "github/Rubncal04/youtube-premium/models"
+
+// Permission is an action a role may perform, checked per route by
+// middleware.RequirePermission
+type Permission string
+
+const (
+	PermissionRead        Permission = "read"         // Read clients, payments and settings
+	PermissionWrite       Permission = "write"        // Create, change and delete them
+	PermissionManageUsers Permission = "users:manage" // List users, change their roles and disable them
+)
+
+var rolePermissions = map[string][]Permission{
+	models.RoleAdmin:     {PermissionRead, PermissionWrite, PermissionManageUsers},
+	models.RoleOwner:     {PermissionRead, PermissionWrite},
+	models.RoleAssistant: {PermissionRead},
+}
+
+// HasPermission reports whether a role grants a permission
+func HasPermission(role string, permission Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 secret of the RFC 6238 test vectors
+const rfc6238Secret = "12345678901234567890"
+
+// The RFC 6238 SHA1 vectors, reduced to the last TOTPDigits digits
+var rfc6238Vectors = []struct {
+	time int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCode(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		step := vector.time / int64(TOTPPeriod.Seconds())
+		if got := totpCode([]byte(rfc6238Secret), step); got != vector.code {
+			t.Errorf("totpCode at %d = %s, want %s", vector.time, got, vector.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte(rfc6238Secret))
+
+	for _, vector := range rfc6238Vectors {
+		now := time.Unix(vector.time, 0)
+		step, ok := ValidateTOTP(secret, vector.code, now)
+		if !ok {
+			t.Errorf("code %s rejected at %d", vector.code, vector.time)
+			continue
+		}
+		if want := vector.time / int64(TOTPPeriod.Seconds()); step != want {
+			t.Errorf("code %s at %d returned step %d, want %d", vector.code, vector.time, step, want)
+		}
+	}
+
+	now := time.Unix(59, 0)
+	tests := []struct {
+		name string
+		code string
+		now  time.Time
+		want bool
+	}{
+		{"spaces in the code", " 287 082 ", now, true},
+		{"previous period", "287082", now.Add(TOTPPeriod), true},
+		{"next period", "287082", now.Add(-TOTPPeriod), true},
+		{"two periods later", "287082", now.Add(2 * TOTPPeriod), false},
+		{"wrong code", "287083", now, false},
+		{"8 digits", "94287082", now, false},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(secret, tt.code, tt.now); ok != tt.want {
+			t.Errorf("%s: valid = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+
+	if _, ok := ValidateTOTP("not base32!", "287082", now); ok {
+		t.Error("code accepted with an invalid secret")
+	}
+}
@@ -0,0 +1,65 @@
+// Package authz decides what a user may do with the clients of an owner: owners
+// may do anything with their own clients, collaborators what their grant allows.
+package authz
+
+import (
+	"errors"
+
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Action is something a user does with the clients of an owner
+type Action string
+
+const (
+	ActionRead           Action = "read"            // See clients, payments and their history
+	ActionManagePayments Action = "manage_payments" // Record payments
+	ActionManage         Action = "manage"          // Change or delete clients, only owners may
+)
+
+var (
+	// ErrNoAccess is returned when the user has no access to the owner's clients
+	ErrNoAccess = errors.New("no access to this owner's clients")
+	// ErrInsufficientScope is returned when the user's grant doesn't allow the action
+	ErrInsufficientScope = errors.New("grant does not allow this action")
+)
+
+// scopeActions lists the actions each grant scope allows
+var scopeActions = map[models.GrantScope][]Action{
+	models.GrantScopeRead:     {ActionRead},
+	models.GrantScopePayments: {ActionRead, ActionManagePayments},
+}
+
+type Service struct {
+	collaboratorRepo *repository.CollaboratorRepository
+}
+
+func NewService(collaboratorRepo *repository.CollaboratorRepository) *Service {
+	return &Service{collaboratorRepo: collaboratorRepo}
+}
+
+// Authorize checks whether a user may perform an action on the clients of an owner
+func (s *Service) Authorize(userID, ownerID primitive.ObjectID, action Action) error {
+	if userID == ownerID {
+		return nil
+	}
+
+	grant, err := s.collaboratorRepo.GetGrant(ownerID, userID)
+	if err != nil {
+		return ErrNoAccess
+	}
+	for _, allowed := range scopeActions[grant.Scope] {
+		if allowed == action {
+			return nil
+		}
+	}
+	return ErrInsufficientScope
+}
+
+// AuthorizeClient checks whether a user may perform an action on a client
+func (s *Service) AuthorizeClient(userID primitive.ObjectID, client *models.Client, action Action) error {
+	return s.Authorize(userID, client.UserID, action)
+}
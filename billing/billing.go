@@ -0,0 +1,209 @@
+// Package billing prices the billing periods of clients and posts them to their
+// ledger: each period costs the owner's price configuration with the client's
+// discounts applied.
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNoPrice is returned when the owner of a client has no price configuration,
+// so its periods can't be priced
+var ErrNoPrice = errors.New("price configuration not found")
+
+// PeriodPrice is what a billing period costs a client
+type PeriodPrice struct {
+	Period    string                   `json:"period"`
+	Base      float64                  `json:"base_amount"` // Owner's price configuration
+	Discounts []models.AppliedDiscount `json:"discounts"`
+	Amount    float64                  `json:"amount"` // Base amount after discounts
+}
+
+type Service struct {
+	ledgerRepo      *repository.LedgerRepository
+	discountRepo    *repository.DiscountRepository
+	priceConfigRepo *repository.PriceConfigurationRepository
+	clientRepo      *repository.ClientRepository
+}
+
+func NewService(ledgerRepo *repository.LedgerRepository, discountRepo *repository.DiscountRepository, priceConfigRepo *repository.PriceConfigurationRepository, clientRepo *repository.ClientRepository) *Service {
+	return &Service{
+		ledgerRepo:      ledgerRepo,
+		discountRepo:    discountRepo,
+		priceConfigRepo: priceConfigRepo,
+		clientRepo:      clientRepo,
+	}
+}
+
+// Price returns what each of the given periods costs the client, in order.
+// Discounts limited to a number of cycles are used up over the periods as they
+// would be when the periods are charged.
+func (s *Service) Price(client models.Client, periods []string) ([]PeriodPrice, error) {
+	config, err := s.priceConfigRepo.GetByUserID(client.UserID)
+	if err != nil || config.Amount <= 0 {
+		return nil, ErrNoPrice
+	}
+
+	discounts, err := s.discountRepo.GetApplicable(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return pricePeriods(config.Amount, discounts, periods), nil
+}
+
+// pricePeriods applies the discounts to the base price of each period, counting
+// the cycles each discount uses
+func pricePeriods(base float64, discounts []models.Discount, periods []string) []PeriodPrice {
+	available := make([]models.Discount, len(discounts))
+	copy(available, discounts)
+	index := make(map[primitive.ObjectID]int, len(available))
+	for i, discount := range available {
+		index[discount.ID] = i
+	}
+
+	prices := make([]PeriodPrice, 0, len(periods))
+	for _, period := range periods {
+		amount, applied := models.ApplyDiscounts(base, available)
+		for _, item := range applied {
+			available[index[item.DiscountID]].CyclesUsed++
+		}
+		prices = append(prices, PeriodPrice{Period: period, Base: base, Discounts: applied, Amount: amount})
+	}
+	return prices
+}
+
+// AmountToSettle returns what the client has to pay for the given periods to be
+// paid: what it already owes, or minus its credit, plus the price of the periods
+// that aren't charged yet
+func (s *Service) AmountToSettle(client models.Client, periods []string) (float64, error) {
+	uncharged := []string{}
+	for _, period := range periods {
+		charged, err := s.ledgerRepo.HasCharge(client.ID, period)
+		if err != nil {
+			return 0, err
+		}
+		if !charged {
+			uncharged = append(uncharged, period)
+		}
+	}
+
+	balance, err := s.ledgerRepo.GetBalance(client.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	amount := -balance
+	if len(uncharged) > 0 {
+		prices, err := s.Price(client, uncharged)
+		if err != nil {
+			return 0, err
+		}
+		for _, price := range prices {
+			amount += price.Amount
+		}
+	}
+	return math.Max(amount, 0), nil
+}
+
+// ChargePeriods posts the charges of the given periods that aren't charged yet,
+// crediting their discounts and using one cycle of each discount applied. It
+// returns how many periods were charged.
+func (s *Service) ChargePeriods(client models.Client, periods []string) (int, error) {
+	charged := 0
+	for _, period := range periods {
+		// Priced one at a time, the discounts used by a charge change the next one
+		prices, err := s.Price(client, []string{period})
+		if err != nil {
+			return charged, err
+		}
+		price := prices[0]
+
+		entry, err := s.ledgerRepo.RecordCharge(client, period, price.Base)
+		if err != nil {
+			return charged, err
+		}
+		if entry == nil {
+			continue // Already charged
+		}
+		charged++
+
+		for _, item := range price.Discounts {
+			if _, err := s.ledgerRepo.RecordDiscount(client, period, item); err != nil {
+				return charged, err
+			}
+			discount, err := s.discountRepo.GetByID(item.DiscountID.Hex())
+			if err != nil {
+				return charged, err
+			}
+			if err := s.discountRepo.IncrementUsage(*discount, client.ID); err != nil {
+				return charged, err
+			}
+		}
+	}
+	return charged, nil
+}
+
+// RecordPayment posts a completed payment to the client's ledger: the payment's
+// credit and the charges of the periods it covers, which the credit settles. The
+// client's status then follows the resulting balance. Without a price
+// configuration only the credit is posted.
+func (s *Service) RecordPayment(client *models.Client, payment *models.Payment) error {
+	if _, err := s.ledgerRepo.RecordPayment(*client, payment); err != nil {
+		return fmt.Errorf("error recording payment: %v", err)
+	}
+
+	if _, err := s.ChargePeriods(*client, payment.CoveredPeriods()); err != nil && !errors.Is(err, ErrNoPrice) {
+		return fmt.Errorf("error charging covered periods: %v", err)
+	}
+
+	balance, err := s.ledgerRepo.GetBalance(client.ID)
+	if err != nil {
+		return err
+	}
+	return s.clientRepo.SyncStatusWithBalance(client, balance)
+}
+
+// RecordImportedPayments posts imported historical payments to the ledger inside
+// the transaction that imports them. Today's price and discounts didn't apply
+// to past periods, so the periods a payment covers are charged what it paid for
+// each of them and no discount cycles are used. The status of each client then
+// follows its resulting balance.
+func (s *Service) RecordImportedPayments(ctx mongo.SessionContext, clients []models.Client, payments []*models.Payment) error {
+	// Copies, a retried transaction starts again from the stored statuses
+	byID := make(map[primitive.ObjectID]*models.Client, len(clients))
+	for i := range clients {
+		client := clients[i]
+		byID[client.ID] = &client
+	}
+
+	balances := map[primitive.ObjectID]float64{}
+	for _, payment := range payments {
+		client := byID[payment.ClientID]
+		periodAmount := payment.Amount / float64(len(payment.CoveredPeriods()))
+
+		balance, err := s.ledgerRepo.RecordImportedPayment(ctx, *client, payment, periodAmount)
+		if err != nil {
+			return fmt.Errorf("error recording payment: %v", err)
+		}
+		balances[client.ID] = balance
+	}
+
+	for clientID, balance := range balances {
+		client := byID[clientID]
+		if status, changed := client.StatusForBalance(balance); changed {
+			if err := s.clientRepo.ChangeStatusInTransaction(ctx, client, status, "payments imported", nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
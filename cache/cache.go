@@ -11,6 +11,9 @@ type Cache interface {
 	Get(ctx context.Context, key string, dest interface{}) error
 	Delete(ctx context.Context, key string) error
 	Clear(ctx context.Context) error
+	// Increment suma uno de forma atómica al contador de la clave, creándolo si no
+	// existe, y renueva su expiración. Devuelve el nuevo valor.
+	Increment(ctx context.Context, key string, expiration time.Duration) (int64, error)
 }
 
 // CacheableRepository define la interfaz que cualquier repositorio que use caché debe implementar
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCacheMiss se devuelve cuando la clave no existe o expiró
+var ErrCacheMiss = errors.New("cache miss")
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time // Cero si no expira
+}
+
+// purgeInterval es cada cuánto se eliminan las entradas expiradas
+const purgeInterval = time.Minute
+
+// MemoryCache es una caché en memoria del proceso, usada cuando Redis no está disponible
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	stop    chan struct{}
+}
+
+// NewMemoryCache crea la caché y empieza a eliminar sus entradas expiradas
+// periódicamente, hasta que se llama a Close
+func NewMemoryCache() *MemoryCache {
+	c := &MemoryCache{entries: map[string]memoryEntry{}, stop: make(chan struct{})}
+	go c.purgeEvery(purgeInterval)
+	return c
+}
+
+// Close detiene la eliminación periódica de entradas expiradas
+func (c *MemoryCache) Close() {
+	close(c.stop)
+}
+
+// Set almacena un valor en caché
+func (c *MemoryCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	entry := memoryEntry{value: data}
+	if expiration > 0 {
+		entry.expiresAt = time.Now().Add(expiration)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+	return nil
+}
+
+// Get obtiene un valor de caché
+func (c *MemoryCache) Get(ctx context.Context, key string, dest interface{}) error {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if !ok || entry.expired(time.Now()) {
+		return ErrCacheMiss
+	}
+	return json.Unmarshal(entry.value, dest)
+}
+
+// Delete elimina un valor de caché
+func (c *MemoryCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	return nil
+}
+
+// Increment incrementa un contador, guardado como JSON para que Get pueda leerlo
+func (c *MemoryCache) Increment(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Las entradas expiradas aún no eliminadas cuentan desde cero
+	var count int64
+	if entry, ok := c.entries[key]; ok && !entry.expired(time.Now()) {
+		if err := json.Unmarshal(entry.value, &count); err != nil {
+			return 0, err
+		}
+	}
+	count++
+
+	data, err := json.Marshal(count)
+	if err != nil {
+		return 0, err
+	}
+	entry := memoryEntry{value: data}
+	if expiration > 0 {
+		entry.expiresAt = time.Now().Add(expiration)
+	}
+	c.entries[key] = entry
+	return count, nil
+}
+
+// Clear limpia toda la caché
+func (c *MemoryCache) Clear(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]memoryEntry{}
+	return nil
+}
+
+// purgeEvery elimina las entradas expiradas cada interval, así Set e Increment
+// no recorren toda la caché en cada llamada
+func (c *MemoryCache) purgeEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.purgeExpired()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// purgeExpired elimina las entradas expiradas
+func (c *MemoryCache) purgeExpired() {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.entries {
+		if entry.expired(now) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
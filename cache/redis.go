@@ -56,6 +56,20 @@ func (c *RedisCache) Delete(ctx context.Context, key string) error {
 	return c.client.Del(ctx, key).Err()
 }
 
+// Increment incrementa un contador en una transacción junto con su expiración
+func (c *RedisCache) Increment(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, expiration)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
 // Clear limpia toda la caché
 func (c *RedisCache) Clear(ctx context.Context) error {
 	return c.client.FlushAll(ctx).Err()
@@ -8,18 +8,33 @@ import (
 )
 
 type EnvVariables struct {
-	PORT                 string
-	MONGO_URI            string
-	MONGO_DB             string
-	TELEGRAM_BOT_TOKEN   string
-	TWILIO_ACCOUNT_SID   string
-	TWILIO_AUTH_TOKEN    string
-	TWILIO_FROM_WHATSAPP string
-	JWT_SECRET_KEY       string
-	REDIS_ADDRESS        string
-	REDIS_PASSWORD       string
-	REDIS_PORT           string
-	REDIS_DATABASES      string
+	PORT                       string
+	MONGO_URI                  string
+	MONGO_DB                   string
+	TELEGRAM_BOT_TOKEN         string
+	TWILIO_ACCOUNT_SID         string
+	TWILIO_AUTH_TOKEN          string
+	TWILIO_FROM_WHATSAPP       string
+	JWT_SECRET_KEY             string
+	JWT_KEYS_FILE              string
+	REDIS_ADDRESS              string
+	REDIS_PASSWORD             string
+	REDIS_PORT                 string
+	REDIS_DATABASES            string
+	CLIENT_RETENTION_DAYS      string
+	SMTP_HOST                  string
+	SMTP_PORT                  string
+	SMTP_USERNAME              string
+	SMTP_PASSWORD              string
+	SMTP_FROM                  string
+	APP_URL                    string
+	RATE_LIMIT_AUTH            string
+	RATE_LIMIT_LOGIN           string
+	RATE_LIMIT_API             string
+	LOGIN_LOCKOUT_THRESHOLD    string
+	LOGIN_LOCKOUT_DURATION     string
+	LOGIN_LOCKOUT_MAX_DURATION string
+	ADMIN_EMAILS               string
 }
 
 func GetVariables() *EnvVariables {
@@ -29,17 +44,32 @@ func GetVariables() *EnvVariables {
 	}
 
 	return &EnvVariables{
-		PORT:                 os.Getenv("PORT"),
-		MONGO_URI:            os.Getenv("MONGO_URI"),
-		MONGO_DB:             os.Getenv("MONGO_DB"),
-		TELEGRAM_BOT_TOKEN:   os.Getenv("TELEGRAM_BOT_TOKEN"),
-		TWILIO_ACCOUNT_SID:   os.Getenv("TWILIO_ACCOUNT_SID"),
-		TWILIO_AUTH_TOKEN:    os.Getenv("TWILIO_AUTH_TOKEN"),
-		TWILIO_FROM_WHATSAPP: os.Getenv("TWILIO_FROM_WHATSAPP"),
-		JWT_SECRET_KEY:       os.Getenv("JWT_SECRET_KEY"),
-		REDIS_ADDRESS:        os.Getenv("REDIS_ADDRESS"),
-		REDIS_PASSWORD:       os.Getenv("REDIS_PASSWORD"),
-		REDIS_PORT:           os.Getenv("REDIS_PORT"),
-		REDIS_DATABASES:      os.Getenv("REDIS_DATABASES"),
+		PORT:                       os.Getenv("PORT"),
+		MONGO_URI:                  os.Getenv("MONGO_URI"),
+		MONGO_DB:                   os.Getenv("MONGO_DB"),
+		TELEGRAM_BOT_TOKEN:         os.Getenv("TELEGRAM_BOT_TOKEN"),
+		TWILIO_ACCOUNT_SID:         os.Getenv("TWILIO_ACCOUNT_SID"),
+		TWILIO_AUTH_TOKEN:          os.Getenv("TWILIO_AUTH_TOKEN"),
+		TWILIO_FROM_WHATSAPP:       os.Getenv("TWILIO_FROM_WHATSAPP"),
+		JWT_SECRET_KEY:             os.Getenv("JWT_SECRET_KEY"),
+		JWT_KEYS_FILE:              os.Getenv("JWT_KEYS_FILE"),
+		REDIS_ADDRESS:              os.Getenv("REDIS_ADDRESS"),
+		REDIS_PASSWORD:             os.Getenv("REDIS_PASSWORD"),
+		REDIS_PORT:                 os.Getenv("REDIS_PORT"),
+		REDIS_DATABASES:            os.Getenv("REDIS_DATABASES"),
+		CLIENT_RETENTION_DAYS:      os.Getenv("CLIENT_RETENTION_DAYS"),
+		SMTP_HOST:                  os.Getenv("SMTP_HOST"),
+		SMTP_PORT:                  os.Getenv("SMTP_PORT"),
+		SMTP_USERNAME:              os.Getenv("SMTP_USERNAME"),
+		SMTP_PASSWORD:              os.Getenv("SMTP_PASSWORD"),
+		SMTP_FROM:                  os.Getenv("SMTP_FROM"),
+		APP_URL:                    os.Getenv("APP_URL"),
+		RATE_LIMIT_AUTH:            os.Getenv("RATE_LIMIT_AUTH"),
+		RATE_LIMIT_LOGIN:           os.Getenv("RATE_LIMIT_LOGIN"),
+		RATE_LIMIT_API:             os.Getenv("RATE_LIMIT_API"),
+		LOGIN_LOCKOUT_THRESHOLD:    os.Getenv("LOGIN_LOCKOUT_THRESHOLD"),
+		LOGIN_LOCKOUT_DURATION:     os.Getenv("LOGIN_LOCKOUT_DURATION"),
+		LOGIN_LOCKOUT_MAX_DURATION: os.Getenv("LOGIN_LOCKOUT_MAX_DURATION"),
+		ADMIN_EMAILS:               os.Getenv("ADMIN_EMAILS"),
 	}
 }
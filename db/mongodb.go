@@ -78,6 +78,68 @@ func (m *MongoRepo) FindAll(collectionName string, filter any, result any) error
 	return nil
 }
 
+// FindAllWithOptions works like FindAll but accepts find options such as sort, skip and limit
+func (m *MongoRepo) FindAllWithOptions(collectionName string, filter any, result any, opts *options.FindOptions) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if str, ok := filter.(string); ok && str == "" {
+		filter = bson.M{}
+	}
+
+	collection := m.Db.Collection(collectionName)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, result); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FindEach streams the documents matching the filter, calling fn with each one
+// instead of loading the whole result in memory. It stops at the first error.
+func (m *MongoRepo) FindEach(collectionName string, filter any, opts *options.FindOptions, fn func(bson.Raw) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if str, ok := filter.(string); ok && str == "" {
+		filter = bson.M{}
+	}
+
+	collection := m.Db.Collection(collectionName)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		if err := fn(cursor.Current); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// CountDocuments returns the number of documents matching the filter
+func (m *MongoRepo) CountDocuments(collectionName string, filter any) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if str, ok := filter.(string); ok && str == "" {
+		filter = bson.M{}
+	}
+
+	collection := m.Db.Collection(collectionName)
+	return collection.CountDocuments(ctx, filter)
+}
+
 func (m *MongoRepo) FindOne(collectionName string, filter any, result any) (any, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -121,6 +183,32 @@ func (m *MongoRepo) UpdateOne(collectionName string, filter any, update any) err
 	return nil
 }
 
+// UpdateOneMatched works like UpdateOne but returns how many documents matched
+// the filter, so callers can tell whether a conditional update was applied
+func (m *MongoRepo) UpdateOneMatched(collectionName string, filter any, update any) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := m.Db.Collection(collectionName)
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.MatchedCount, nil
+}
+
+// FindOneAndUpdate applies an update to the first document matching the filter
+// and decodes the document into result, as it is after the update when opts
+// ask for it
+func (m *MongoRepo) FindOneAndUpdate(collectionName string, filter any, update any, result any, opts *options.FindOneAndUpdateOptions) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := m.Db.Collection(collectionName)
+	return collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(result)
+}
+
 // UpdateMany updates multiple documents in a collection
 func (m *MongoRepo) UpdateMany(collectionName string, filter any, update any) (*mongo.UpdateResult, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -148,3 +236,111 @@ func (m *MongoRepo) DeleteOne(collectionName string, filter any) error {
 
 	return nil
 }
+
+// DeleteMany deletes every document matching the filter and returns how many were deleted
+func (m *MongoRepo) DeleteMany(collectionName string, filter any) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := m.Db.Collection(collectionName)
+	result, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+// Aggregate runs an aggregation pipeline and decodes the resulting documents into result
+func (m *MongoRepo) Aggregate(collectionName string, pipeline any, result any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := m.Db.Collection(collectionName)
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, result); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateIndexes creates the given indexes on a collection, existing indexes are left untouched
+func (m *MongoRepo) CreateIndexes(collectionName string, indexes []mongo.IndexModel) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := m.Db.Collection(collectionName)
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// WithTransaction runs fn inside a multi-document transaction, committing it when
+// fn returns nil and aborting it otherwise. Operations inside fn must use the
+// given context. Transactions require MongoDB to run as a replica set.
+func (m *MongoRepo) WithTransaction(fn func(ctx mongo.SessionContext) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	session, err := m.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (any, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// InsertMany inserts several documents at once using the given context, so it can
+// take part in a transaction
+func (m *MongoRepo) InsertMany(ctx context.Context, collectionName string, docs []any) ([]any, error) {
+	collection := m.Db.Collection(collectionName)
+	result, err := collection.InsertMany(ctx, docs)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.InsertedIDs, nil
+}
+
+// UpdateOneWithContext works like UpdateOne but uses the given context, so it can
+// take part in a transaction
+func (m *MongoRepo) UpdateOneWithContext(ctx context.Context, collectionName string, filter any, update any) error {
+	collection := m.Db.Collection(collectionName)
+	_, err := collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// UpdateOneMatchedWithContext works like UpdateOneMatched but uses the given
+// context, so it can take part in a transaction
+func (m *MongoRepo) UpdateOneMatchedWithContext(ctx context.Context, collectionName string, filter any, update any) (int64, error) {
+	collection := m.Db.Collection(collectionName)
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.MatchedCount, nil
+}
+
+// FindOneAndUpdateWithContext works like FindOneAndUpdate but uses the given
+// context, so it can take part in a transaction
+func (m *MongoRepo) FindOneAndUpdateWithContext(ctx context.Context, collectionName string, filter any, update any, result any, opts *options.FindOneAndUpdateOptions) error {
+	collection := m.Db.Collection(collectionName)
+	return collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(result)
+}
+
+// CountDocumentsWithContext works like CountDocuments but uses the given context,
+// so it can take part in a transaction
+func (m *MongoRepo) CountDocumentsWithContext(ctx context.Context, collectionName string, filter any) (int64, error) {
+	collection := m.Db.Collection(collectionName)
+	return collection.CountDocuments(ctx, filter)
+}
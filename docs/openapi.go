@@ -0,0 +1,220 @@
+package docs
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github/Rubncal04/youtube-premium/handlers"
+
+	"github.com/labstack/echo/v4"
+)
+
+// APIPrefix is the prefix of the authenticated routes
+const APIPrefix = "/api/v1"
+
+// operation documents a route. Path uses Echo's syntax (:id) relative to
+// APIPrefix, unless the operation is public.
+type operation struct {
+	Method      string
+	Path        string
+	Tag         string
+	Summary     string
+	Public      bool    // Served without authentication and outside APIPrefix
+	Session     bool    // Takes a bearer token, API keys are rejected
+	Params      []param // Query and header parameters, path parameters are taken from Path
+	Request     any     // Example value of the JSON body, nil when there is none
+	RequestType string  // Content type of the body, defaults to application/json
+	Status      int     // Status of a successful response
+	Response    any     // Example value of the successful body, nil when there is none
+	Download    string  // Content type of a file download returned instead of JSON
+}
+
+type param struct {
+	Name        string
+	In          string // query or header, defaults to query
+	Type        string // string, integer, number or boolean
+	Format      string
+	Description string
+	Required    bool
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// Route returns the full Echo path of an operation
+func (o operation) Route() string {
+	if o.Public {
+		return o.Path
+	}
+	return APIPrefix + o.Path
+}
+
+var (
+	specOnce sync.Once
+	spec     map[string]any
+)
+
+// Spec returns the OpenAPI 3 document describing the API
+func Spec() map[string]any {
+	specOnce.Do(func() {
+		spec = buildSpec(operations)
+	})
+	return spec
+}
+
+// HasOperation reports whether the document describes a route, given with
+// Echo's path syntax
+func HasOperation(method, path string) bool {
+	for _, op := range operations {
+		if op.Method == method && op.Route() == path {
+			return true
+		}
+	}
+	return false
+}
+
+// Routes returns the method and Echo path of every documented operation
+func Routes() []echo.Route {
+	routes := make([]echo.Route, 0, len(operations))
+	for _, op := range operations {
+		routes = append(routes, echo.Route{Method: op.Method, Path: op.Route()})
+	}
+	return routes
+}
+
+func buildSpec(ops []operation) map[string]any {
+	registry := newSchemaRegistry()
+	errorRef := registry.schemaOf(handlers.ErrorResponse{})
+
+	paths := map[string]map[string]any{}
+	for _, op := range ops {
+		path := pathParam.ReplaceAllString(op.Route(), "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+
+		parameters := []map[string]any{}
+		for _, match := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+			parameters = append(parameters, map[string]any{
+				"name": match[1], "in": "path", "required": true, "schema": Schema{"type": "string"},
+			})
+		}
+		for _, p := range op.Params {
+			in := p.In
+			if in == "" {
+				in = "query"
+			}
+			schema := Schema{"type": p.Type}
+			if p.Format != "" {
+				schema["format"] = p.Format
+			}
+			parameters = append(parameters, map[string]any{
+				"name": p.Name, "in": in, "required": p.Required, "description": p.Description, "schema": schema,
+			})
+		}
+
+		responses := map[string]any{
+			"default": map[string]any{
+				"description": "Error",
+				"content":     map[string]any{"application/json": map[string]any{"schema": errorRef}},
+			},
+		}
+		success := map[string]any{"description": http.StatusText(op.Status)}
+		switch {
+		case op.Download != "":
+			content := map[string]any{}
+			for _, contentType := range strings.Split(op.Download, ",") {
+				content[strings.TrimSpace(contentType)] = map[string]any{
+					"schema": Schema{"type": "string", "format": "binary"},
+				}
+			}
+			success["content"] = content
+		case op.Response != nil:
+			success["content"] = map[string]any{"application/json": map[string]any{
+				"schema": registry.schemaOf(op.Response),
+			}}
+		}
+		responses[strconv.Itoa(op.Status)] = success
+
+		operation := map[string]any{
+			"tags":        []string{op.Tag},
+			"summary":     op.Summary,
+			"operationId": operationID(op),
+			"parameters":  parameters,
+			"responses":   responses,
+		}
+		if op.Request != nil {
+			contentType := op.RequestType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{contentType: map[string]any{"schema": registry.schemaOf(op.Request)}},
+			}
+		}
+		if op.Public {
+			operation["security"] = []any{}
+		} else if op.Session {
+			operation["security"] = []any{map[string]any{"bearerAuth": []string{}}}
+		}
+
+		paths[path][strings.ToLower(op.Method)] = operation
+	}
+
+	schemas := map[string]any{}
+	for name, schema := range registry.components {
+		schemas[name] = schema
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "YouTube Premium Payment Management API",
+			"description": "API for managing YouTube Premium payments, clients and notifications.",
+			"version":     "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKeyAuth": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		"security": []any{map[string]any{"bearerAuth": []string{}}, map[string]any{"apiKeyAuth": []string{}}},
+	}
+}
+
+// operationID builds an identifier such as get_clients_id_ledger
+func operationID(op operation) string {
+	path := strings.NewReplacer("/", "_", ":", "", "-", "_", ".", "_").Replace(op.Path)
+	path = strings.Trim(path, "_")
+	return strings.ToLower(op.Method) + "_" + path
+}
+
+// Register serves the OpenAPI document at /openapi.json and a Redoc UI at /docs
+func Register(e *echo.Echo) {
+	e.GET("/openapi.json", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, Spec())
+	})
+	e.GET("/docs", func(c echo.Context) error {
+		return c.HTML(http.StatusOK, redocPage)
+	})
+}
+
+const redocPage = `<!DOCTYPE html>
+<html>
+<head>
+	<title>YouTube Premium API</title>
+	<meta charset="utf-8"/>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+	<redoc spec-url="/openapi.json"></redoc>
+	<script src="https://cdn.jsdelivr.net/npm/redoc@2.1.5/bundles/redoc.standalone.js" crossorigin="anonymous"></script>
+</body>
+</html>
+`
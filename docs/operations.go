@@ -0,0 +1,233 @@
+package docs
+
+import (
+	"net/http"
+
+	"github/Rubncal04/youtube-premium/auth"
+	"github/Rubncal04/youtube-premium/handlers"
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/repository"
+)
+
+// MessageResponse is the body of the endpoints that only confirm an action
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+var cursorParams = []param{
+	{Name: "sort", Type: "string", Description: "Field to sort by, prefixed with - for descending order"},
+	{Name: "limit", Type: "integer", Description: "Page size, 20 by default and 100 at most"},
+	{Name: "cursor", Type: "string", Description: "next_cursor returned by the previous page"},
+}
+
+var clientFilterParams = []param{
+	{Name: "status", Type: "string", Description: "Client status"},
+	{Name: "day_from", Type: "integer", Description: "Minimum day to pay"},
+	{Name: "day_to", Type: "integer", Description: "Maximum day to pay"},
+	{Name: "paid_from", Type: "string", Description: "Last payment date from (YYYY-MM-DD or RFC 3339)"},
+	{Name: "paid_to", Type: "string", Description: "Last payment date to (YYYY-MM-DD or RFC 3339)"},
+	{Name: "q", Type: "string", Description: "Case insensitive search on the name"},
+}
+
+var paymentFilterParams = []param{
+	{Name: "status", Type: "string", Description: "Payment status"},
+	{Name: "date_from", Type: "string", Description: "Payment date from (YYYY-MM-DD or RFC 3339)"},
+	{Name: "date_to", Type: "string", Description: "Payment date to (YYYY-MM-DD or RFC 3339)"},
+	{Name: "amount_min", Type: "number", Description: "Minimum amount"},
+	{Name: "amount_max", Type: "number", Description: "Maximum amount"},
+}
+
+var ownerParam = param{Name: "owner_id", Type: "string", Description: "Owner whose data to list, for collaborators"}
+
+var formatParam = param{Name: "format", Type: "string", Description: "csv (default) or xlsx"}
+
+var dryRunParam = param{Name: "dry_run", Type: "boolean", Description: "Only validate the rows"}
+
+var ifMatchParam = param{Name: "If-Match", In: "header", Type: "string", Required: true, Description: "ETag of the client being modified, or *"}
+
+const (
+	xlsxType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	csvType  = "text/csv"
+)
+
+func params(groups ...[]param) []param {
+	var all []param
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+	return all
+}
+
+// operations lists every route registered by routes.RegisterRoutes
+var operations = []operation{
+	// Authentication
+	{Method: http.MethodPost, Path: "/register", Public: true, Tag: "Authentication", Summary: "Register a user",
+		Request: handlers.RegisterRequest{}, Status: http.StatusCreated, Response: MessageResponse{}},
+	{Method: http.MethodPost, Path: "/login", Public: true, Tag: "Authentication", Summary: "Log in, users with two-factor authentication get a TwoFactorChallengeResponse instead",
+		Request: handlers.LoginRequest{}, Status: http.StatusOK, Response: handlers.AuthResponse{}},
+	{Method: http.MethodPost, Path: "/login/2fa", Public: true, Tag: "Authentication", Summary: "Exchange a two-factor challenge and a TOTP or recovery code for a token pair",
+		Request: handlers.TwoFactorLoginRequest{}, Status: http.StatusOK, Response: handlers.AuthResponse{}},
+	{Method: http.MethodPost, Path: "/refresh", Public: true, Tag: "Authentication", Summary: "Get a new token pair",
+		Request: handlers.RefreshRequest{}, Status: http.StatusOK, Response: auth.TokenPair{}},
+	{Method: http.MethodGet, Path: "/.well-known/jwks.json", Public: true, Tag: "Authentication", Summary: "Get the public keys verifying tokens",
+		Status: http.StatusOK, Response: auth.JWKS{}},
+	{Method: http.MethodPost, Path: "/logout", Public: true, Tag: "Authentication", Summary: "Revoke the session of a refresh token",
+		Request: handlers.RefreshRequest{}, Status: http.StatusOK, Response: MessageResponse{}},
+	{Method: http.MethodPost, Path: "/logout/all", Session: true, Tag: "Authentication", Summary: "Revoke every session of the user",
+		Status: http.StatusOK, Response: MessageResponse{}},
+	{Method: http.MethodPut, Path: "/me/password", Session: true, Tag: "Authentication", Summary: "Change the password and revoke every session",
+		Request: handlers.ChangePasswordRequest{}, Status: http.StatusOK, Response: MessageResponse{}},
+	{Method: http.MethodPost, Path: "/verify-email", Public: true, Tag: "Authentication", Summary: "Verify the email with the token of the emailed link",
+		Request: handlers.VerifyEmailRequest{}, Status: http.StatusOK, Response: MessageResponse{}},
+	{Method: http.MethodPost, Path: "/me/verification", Session: true, Tag: "Authentication", Summary: "Email a new verification link",
+		Status: http.StatusOK, Response: MessageResponse{}},
+	{Method: http.MethodPost, Path: "/me/2fa/enroll", Session: true, Tag: "Authentication", Summary: "Start enrolling in two-factor authentication",
+		Status: http.StatusOK, Response: handlers.TwoFactorEnrollmentResponse{}},
+	{Method: http.MethodPost, Path: "/me/2fa/confirm", Session: true, Tag: "Authentication", Summary: "Enable two-factor authentication with a code and get the recovery codes",
+		Request: handlers.TwoFactorCodeRequest{}, Status: http.StatusOK, Response: handlers.RecoveryCodesResponse{}},
+	{Method: http.MethodPost, Path: "/me/2fa/recovery-codes", Session: true, Tag: "Authentication", Summary: "Replace the recovery codes",
+		Request: handlers.TwoFactorCodeRequest{}, Status: http.StatusOK, Response: handlers.RecoveryCodesResponse{}},
+	{Method: http.MethodPost, Path: "/me/2fa/disable", Session: true, Tag: "Authentication", Summary: "Disable two-factor authentication",
+		Request: handlers.DisableTwoFactorRequest{}, Status: http.StatusOK, Response: MessageResponse{}},
+	{Method: http.MethodPost, Path: "/password/forgot", Public: true, Tag: "Authentication", Summary: "Email a password reset link",
+		Request: handlers.ForgotPasswordRequest{}, Status: http.StatusOK, Response: MessageResponse{}},
+	{Method: http.MethodPost, Path: "/password/reset", Public: true, Tag: "Authentication", Summary: "Set a new password with the token of a reset link and revoke every session",
+		Request: handlers.ResetPasswordRequest{}, Status: http.StatusOK, Response: MessageResponse{}},
+
+	// API keys
+	{Method: http.MethodPost, Path: "/api-keys", Session: true, Tag: "API keys", Summary: "Create an API key, returned once",
+		Request: handlers.APIKeyRequest{}, Status: http.StatusCreated, Response: handlers.APIKeyResponse{}},
+	{Method: http.MethodGet, Path: "/api-keys", Session: true, Tag: "API keys", Summary: "List the active API keys",
+		Status: http.StatusOK, Response: []models.APIKey{}},
+	{Method: http.MethodDelete, Path: "/api-keys/:id", Session: true, Tag: "API keys", Summary: "Revoke an API key",
+		Status: http.StatusOK, Response: MessageResponse{}},
+
+	// Admin
+	{Method: http.MethodGet, Path: "/admin/users", Tag: "Admin", Summary: "List users",
+		Status: http.StatusOK, Response: []models.User{}},
+	{Method: http.MethodPut, Path: "/admin/users/:id/role", Tag: "Admin", Summary: "Change the role of a user",
+		Request: handlers.RoleRequest{}, Status: http.StatusOK, Response: models.User{}},
+	{Method: http.MethodPost, Path: "/admin/users/:id/disable", Tag: "Admin", Summary: "Disable a user and revoke their sessions",
+		Status: http.StatusOK, Response: models.User{}},
+	{Method: http.MethodPost, Path: "/admin/users/:id/enable", Tag: "Admin", Summary: "Enable a disabled user",
+		Status: http.StatusOK, Response: models.User{}},
+
+	// Collaborators
+	{Method: http.MethodPost, Path: "/collaborators/invitations", Tag: "Collaborators", Summary: "Invite a user to access your clients",
+		Request: handlers.InvitationRequest{}, Status: http.StatusCreated, Response: models.Invitation{}},
+	{Method: http.MethodGet, Path: "/collaborators/invitations", Tag: "Collaborators", Summary: "List pending invitations",
+		Status: http.StatusOK, Response: []models.Invitation{}},
+	{Method: http.MethodDelete, Path: "/collaborators/invitations/:id", Tag: "Collaborators", Summary: "Revoke a pending invitation",
+		Status: http.StatusOK, Response: MessageResponse{}},
+	{Method: http.MethodGet, Path: "/collaborators", Tag: "Collaborators", Summary: "List collaborators",
+		Status: http.StatusOK, Response: []models.Grant{}},
+	{Method: http.MethodPut, Path: "/collaborators/:id", Tag: "Collaborators", Summary: "Change the scope of a collaborator",
+		Request: handlers.GrantScopeRequest{}, Status: http.StatusOK, Response: models.Grant{}},
+	{Method: http.MethodDelete, Path: "/collaborators/:id", Tag: "Collaborators", Summary: "Remove a collaborator",
+		Status: http.StatusOK, Response: MessageResponse{}},
+	{Method: http.MethodPost, Path: "/invitations/accept", Session: true, Tag: "Collaborators", Summary: "Accept an invitation",
+		Request: handlers.AcceptInvitationRequest{}, Status: http.StatusOK, Response: models.Grant{}},
+	{Method: http.MethodGet, Path: "/shared", Tag: "Collaborators", Summary: "List the owners that gave you access",
+		Status: http.StatusOK, Response: []models.Grant{}},
+
+	// Price configuration
+	{Method: http.MethodPost, Path: "/price-configuration", Tag: "Price configuration", Summary: "Create the monthly price",
+		Request: handlers.PriceConfigRequest{}, Status: http.StatusCreated, Response: models.PriceConfiguration{}},
+	{Method: http.MethodGet, Path: "/price-configuration", Tag: "Price configuration", Summary: "Get the monthly price",
+		Status: http.StatusOK, Response: models.PriceConfiguration{}},
+	{Method: http.MethodPut, Path: "/price-configuration", Tag: "Price configuration", Summary: "Update the monthly price",
+		Request: handlers.PriceConfigRequest{}, Status: http.StatusOK, Response: MessageResponse{}},
+	{Method: http.MethodDelete, Path: "/price-configuration", Tag: "Price configuration", Summary: "Delete the monthly price",
+		Status: http.StatusOK, Response: MessageResponse{}},
+
+	// Payments
+	{Method: http.MethodGet, Path: "/clients/:clientId/payments/:id", Tag: "Payments", Summary: "Get a payment of a client",
+		Status: http.StatusOK, Response: models.Payment{}},
+	{Method: http.MethodGet, Path: "/clients/:clientId/payments", Tag: "Payments", Summary: "List the payments of a client",
+		Status: http.StatusOK, Response: []models.Payment{}},
+	{Method: http.MethodPost, Path: "/clients/:clientId/payments", Tag: "Payments", Summary: "Create a payment",
+		Request: handlers.PaymentRequest{}, Status: http.StatusCreated, Response: models.Payment{}},
+	{Method: http.MethodGet, Path: "/payments", Tag: "Payments", Summary: "List the payments of every client",
+		Params: params(paymentFilterParams, cursorParams, []param{ownerParam}), Status: http.StatusOK, Response: repository.Page[models.Payment]{}},
+	{Method: http.MethodGet, Path: "/payments/export", Tag: "Payments", Summary: "Export payments",
+		Params: params(paymentFilterParams, cursorParams[:1], []param{formatParam}), Status: http.StatusOK, Download: csvType + ", " + xlsxType},
+	{Method: http.MethodPost, Path: "/payments/import", Tag: "Payments", Summary: "Import historical payments from CSV",
+		Params: []param{dryRunParam}, Request: "", RequestType: csvType, Status: http.StatusCreated, Response: handlers.ImportResult{}},
+
+	// Ledger
+	{Method: http.MethodGet, Path: "/clients/:id/ledger", Tag: "Ledger", Summary: "Get the ledger and balance of a client",
+		Params: cursorParams[1:], Status: http.StatusOK, Response: handlers.LedgerResponse{}},
+	{Method: http.MethodPost, Path: "/clients/:id/ledger", Tag: "Ledger", Summary: "Record a refund or adjustment",
+		Request: handlers.LedgerEntryRequest{}, Status: http.StatusCreated, Response: models.LedgerEntry{}},
+	{Method: http.MethodGet, Path: "/clients/:id/ledger/export", Tag: "Ledger", Summary: "Export the ledger of a client",
+		Params: []param{formatParam}, Status: http.StatusOK, Download: csvType + ", " + xlsxType},
+
+	// Discounts
+	{Method: http.MethodPost, Path: "/discounts", Tag: "Discounts", Summary: "Create a discount",
+		Request: handlers.DiscountRequest{}, Status: http.StatusCreated, Response: models.Discount{}},
+	{Method: http.MethodGet, Path: "/discounts", Tag: "Discounts", Summary: "List discounts",
+		Status: http.StatusOK, Response: []models.Discount{}},
+	{Method: http.MethodDelete, Path: "/discounts/:id", Tag: "Discounts", Summary: "Deactivate a discount",
+		Status: http.StatusOK, Response: MessageResponse{}},
+	{Method: http.MethodGet, Path: "/clients/:id/amount-due", Tag: "Discounts", Summary: "Get the amount a client owes",
+		Status: http.StatusOK, Response: handlers.AmountDueResponse{}},
+
+	// Late fees
+	{Method: http.MethodGet, Path: "/late-fee-policy", Tag: "Late fees", Summary: "Get the late fee policy",
+		Status: http.StatusOK, Response: models.LateFeePolicy{}},
+	{Method: http.MethodPut, Path: "/late-fee-policy", Tag: "Late fees", Summary: "Create or update the late fee policy",
+		Request: handlers.LateFeePolicyRequest{}, Status: http.StatusOK, Response: models.LateFeePolicy{}},
+	{Method: http.MethodDelete, Path: "/late-fee-policy", Tag: "Late fees", Summary: "Delete the late fee policy",
+		Status: http.StatusOK, Response: MessageResponse{}},
+	{Method: http.MethodPost, Path: "/clients/:id/late-fees/:entryId/reverse", Tag: "Late fees", Summary: "Reverse a late fee",
+		Status: http.StatusCreated, Response: models.LedgerEntry{}},
+
+	// Stats
+	{Method: http.MethodGet, Path: "/stats", Tag: "Stats", Summary: "Get dashboard stats",
+		Params: []param{
+			{Name: "from", Type: "string", Description: "Start of the range, the current month by default"},
+			{Name: "to", Type: "string", Description: "End of the range, the current month by default"},
+		},
+		Status: http.StatusOK, Response: models.Stats{}},
+
+	// Clients
+	{Method: http.MethodPost, Path: "/clients", Tag: "Clients", Summary: "Create a client",
+		Request: handlers.ClientRequest{}, Status: http.StatusCreated, Response: models.Client{}},
+	{Method: http.MethodGet, Path: "/clients", Tag: "Clients", Summary: "List clients",
+		Params: params(clientFilterParams, cursorParams, []param{ownerParam}), Status: http.StatusOK, Response: repository.Page[models.Client]{}},
+	{Method: http.MethodGet, Path: "/clients/trash", Tag: "Clients", Summary: "List the clients in the trash",
+		Status: http.StatusOK, Response: []models.Client{}},
+	{Method: http.MethodGet, Path: "/clients/export", Tag: "Clients", Summary: "Export clients",
+		Params: params(clientFilterParams, cursorParams[:1], []param{formatParam}), Status: http.StatusOK, Download: csvType + ", " + xlsxType},
+	{Method: http.MethodPost, Path: "/clients/import", Tag: "Clients", Summary: "Import clients from CSV",
+		Params: []param{dryRunParam}, Request: "", RequestType: csvType, Status: http.StatusCreated, Response: handlers.ImportResult{}},
+	{Method: http.MethodPost, Path: "/clients/bulk/update", Tag: "Clients", Summary: "Set the payment day of several clients",
+		Request: handlers.BulkUpdateRequest{}, Status: http.StatusOK, Response: handlers.BulkResult{}},
+	{Method: http.MethodPost, Path: "/clients/bulk/status", Tag: "Clients", Summary: "Change the status of several clients",
+		Request: handlers.BulkStatusRequest{}, Status: http.StatusOK, Response: handlers.BulkResult{}},
+	{Method: http.MethodPost, Path: "/clients/bulk/message", Tag: "Clients", Summary: "Send a message to several clients",
+		Request: handlers.BulkMessageRequest{}, Status: http.StatusAccepted, Response: handlers.BulkResult{}},
+	{Method: http.MethodPost, Path: "/clients/bulk/delete", Tag: "Clients", Summary: "Move several clients to the trash",
+		Request: handlers.BulkRequest{}, Status: http.StatusOK, Response: handlers.BulkResult{}},
+	{Method: http.MethodGet, Path: "/clients/:id", Tag: "Clients", Summary: "Get a client",
+		Status: http.StatusOK, Response: models.Client{}},
+	{Method: http.MethodPut, Path: "/clients/:id", Tag: "Clients", Summary: "Replace a client's details",
+		Request: handlers.ClientRequest{}, Status: http.StatusOK, Response: models.Client{}},
+	{Method: http.MethodPatch, Path: "/clients/:id", Tag: "Clients", Summary: "Partially update a client (JSON Merge Patch)",
+		Params: []param{ifMatchParam}, Request: map[string]any{}, RequestType: "application/merge-patch+json",
+		Status: http.StatusOK, Response: models.Client{}},
+	{Method: http.MethodDelete, Path: "/clients/:id", Tag: "Clients", Summary: "Move a client to the trash",
+		Status: http.StatusOK, Response: MessageResponse{}},
+	{Method: http.MethodPost, Path: "/clients/:id/pause", Tag: "Clients", Summary: "Suspend a client",
+		Request: handlers.StatusChangeRequest{}, Status: http.StatusOK, Response: models.Client{}},
+	{Method: http.MethodPost, Path: "/clients/:id/resume", Tag: "Clients", Summary: "Resume a suspended or cancelled client",
+		Request: handlers.StatusChangeRequest{}, Status: http.StatusOK, Response: models.Client{}},
+	{Method: http.MethodPost, Path: "/clients/:id/cancel", Tag: "Clients", Summary: "Cancel a client",
+		Request: handlers.StatusChangeRequest{}, Status: http.StatusOK, Response: models.Client{}},
+	{Method: http.MethodPost, Path: "/clients/:id/archive", Tag: "Clients", Summary: "Archive a cancelled client",
+		Request: handlers.StatusChangeRequest{}, Status: http.StatusOK, Response: models.Client{}},
+	{Method: http.MethodPost, Path: "/clients/:id/restore", Tag: "Clients", Summary: "Restore a client from the trash",
+		Status: http.StatusOK, Response: models.Client{}},
+	{Method: http.MethodGet, Path: "/clients/:id/status-history", Tag: "Clients", Summary: "Get the status changes of a client",
+		Status: http.StatusOK, Response: []models.ClientStatusChange{}},
+}
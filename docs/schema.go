@@ -0,0 +1,163 @@
+package docs
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github/Rubncal04/youtube-premium/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Schema is an OpenAPI schema object
+type Schema map[string]any
+
+// enums lists the values of the string types used as enumerations by the models
+var enums = map[reflect.Type][]any{
+	reflect.TypeOf(models.ClientStatus("")): {
+		models.ClientStatusPending, models.ClientStatusActive, models.ClientStatusGrace,
+		models.ClientStatusSuspended, models.ClientStatusCancelled, models.ClientStatusArchived,
+	},
+	reflect.TypeOf(models.PaymentStatus("")): {
+		models.PaymentStatusProcessing, models.PaymentStatusCompleted, models.PaymentStatusRejected,
+	},
+	reflect.TypeOf(models.LedgerEntryType("")): {models.LedgerEntryDebit, models.LedgerEntryCredit},
+	reflect.TypeOf(models.LedgerEntryKind("")): {
+		models.LedgerKindCharge, models.LedgerKindPayment, models.LedgerKindRefund, models.LedgerKindAdjustment,
+		models.LedgerKindDiscount, models.LedgerKindLateFee, models.LedgerKindFeeReverse,
+	},
+	reflect.TypeOf(models.DiscountType("")): {models.DiscountTypePercentage, models.DiscountTypeFixed},
+	reflect.TypeOf(models.LateFeeType("")):  {models.LateFeeTypeFlat, models.LateFeeTypePercentage},
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+// schemaRegistry builds schemas from Go types following their json tags. Named
+// structs are added once to the components and referenced with $ref.
+type schemaRegistry struct {
+	components map[string]Schema
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{components: map[string]Schema{}}
+}
+
+// schemaOf returns the schema of a value's type
+func (r *schemaRegistry) schemaOf(value any) Schema {
+	return r.schemaFor(reflect.TypeOf(value))
+}
+
+func (r *schemaRegistry) schemaFor(t reflect.Type) Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case objectIDType:
+		return Schema{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		schema := Schema{"type": "string"}
+		if values, ok := enums[t]; ok {
+			schema["enum"] = values
+		}
+		return schema
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": r.schemaFor(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": r.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := r.components[name]; !ok {
+			// Reserve the name first so recursive types end in a $ref
+			r.components[name] = Schema{}
+			r.components[name] = r.structSchema(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + name}
+	default:
+		return Schema{}
+	}
+}
+
+// structSchema describes the fields of a struct, inlining embedded structs like
+// encoding/json does. Fields with a "required" validation rule are required.
+func (r *schemaRegistry) structSchema(t reflect.Type) Schema {
+	properties := Schema{}
+	required := []string{}
+	r.addFields(t, properties, &required)
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (r *schemaRegistry) addFields(t reflect.Type, properties Schema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name := strings.SplitN(tag, ",", 2)[0]
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				r.addFields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = r.schemaFor(field.Type)
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			if rule == "required" {
+				*required = append(*required, name)
+			}
+		}
+	}
+}
+
+// schemaName names the component of a type, generic types such as
+// Page[models.Client] become PageClient
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	open := strings.Index(name, "[")
+	if open < 0 {
+		return name
+	}
+
+	base := name[:open]
+	for _, arg := range strings.Split(strings.TrimSuffix(name[open+1:], "]"), ",") {
+		if dot := strings.LastIndex(arg, "."); dot >= 0 {
+			arg = arg[dot+1:]
+		}
+		base += arg
+	}
+	return base
+}
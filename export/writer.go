@@ -0,0 +1,138 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Supported export formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// flushEvery is how many CSV rows are buffered before being sent to the client
+const flushEvery = 500
+
+// Writer writes a table row by row so exports don't need every row in memory
+type Writer interface {
+	// WriteRow writes the next row of the table
+	WriteRow(values ...any) error
+	// Close finishes the document and writes whatever is still buffered
+	Close() error
+}
+
+// ContentType returns the MIME type of an export format
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// NewWriter creates a writer for the given format ("csv" or "xlsx") that writes to w
+func NewWriter(format string, w io.Writer, sheet string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w, sheet)
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+	rows   int
+}
+
+func (cw *csvWriter) WriteRow(values ...any) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		if text, ok := value.(string); ok {
+			record[i] = escapeFormula(text)
+			continue
+		}
+		record[i] = fmt.Sprint(value)
+	}
+
+	if err := cw.writer.Write(record); err != nil {
+		return err
+	}
+
+	cw.rows++
+	if cw.rows%flushEvery == 0 {
+		cw.writer.Flush()
+		return cw.writer.Error()
+	}
+	return nil
+}
+
+// numberLike matches numbers and phone numbers such as +57 300 123 4567, which
+// can't call functions or reference cells
+var numberLike = regexp.MustCompile(`^[+-]?[0-9 ().-]+$`)
+
+// escapeFormula prefixes text that spreadsheets would run as a formula with a
+// quote, so names and descriptions typed by users are shown as they are. Only
+// text is escaped, negative amounts and phone numbers stay as they are.
+func escapeFormula(text string) string {
+	if numberLike.MatchString(text) {
+		return text
+	}
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func (cw *csvWriter) Close() error {
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+// xlsxWriter uses excelize's stream writer, which keeps rows on disk instead of
+// memory once the sheet grows. The file is sent to w when closed.
+type xlsxWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXWriter(w io.Writer, sheet string) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName("Sheet1", sheet); err != nil {
+		return nil, err
+	}
+
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xlsxWriter{out: w, file: file, stream: stream}, nil
+}
+
+func (xw *xlsxWriter) WriteRow(values ...any) error {
+	xw.row++
+	cell, err := excelize.CoordinatesToCellName(1, xw.row)
+	if err != nil {
+		return err
+	}
+	return xw.stream.SetRow(cell, values)
+}
+
+func (xw *xlsxWriter) Close() error {
+	defer xw.file.Close()
+
+	if err := xw.stream.Flush(); err != nil {
+		return err
+	}
+	return xw.file.Write(xw.out)
+}
@@ -0,0 +1,65 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+
+	"github/Rubncal04/youtube-premium/importer"
+)
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Ana", "Ana"},
+		{"", ""},
+		{"+573001234567", "+573001234567"},
+		{"+57 (300) 123-4567", "+57 (300) 123-4567"},
+		{"-12.50", "-12.50"},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1+cmd|' /C calc'!A0", "'+1+cmd|' /C calc'!A0"},
+		{"-2+3+cmd", "'-2+3+cmd"},
+		{"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"\tdata", "'\tdata"},
+	}
+
+	for _, tt := range tests {
+		if got := escapeFormula(tt.text); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCSVPhonesSurviveImport(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewWriter(FormatCSV, &buf, "Clients")
+	if err != nil {
+		t.Fatal(err)
+	}
+	phones := []string{"+573001234567", "+57 300 123 4567"}
+	if err := writer.WriteRow("name", "cell_phone", "day_to_pay"); err != nil {
+		t.Fatal(err)
+	}
+	for _, phone := range phones {
+		if err := writer.WriteRow("Ana", phone, 5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := importer.ReadCSV(&buf, []string{"name", "cell_phone", "day_to_pay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(phones) {
+		t.Fatalf("read %d records, want %d", len(records), len(phones))
+	}
+	for i, record := range records {
+		if got := record.Get("cell_phone"); got != phones[i] {
+			t.Errorf("cell_phone = %q, want %q", got, phones[i])
+		}
+	}
+}
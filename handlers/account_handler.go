@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github/Rubncal04/youtube-premium/auth"
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/notifications"
+	"github/Rubncal04/youtube-premium/ratelimit"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AccountHandler handles the flows that email a link to the user: verifying
+// their email and resetting a forgotten password
+type AccountHandler struct {
+	userRepo         *repository.UserRepository
+	accountTokenRepo *repository.AccountTokenRepository
+	refreshTokenRepo *repository.RefreshTokenRepository
+	denylist         *auth.Denylist
+	keys             *auth.KeySet
+	mailer           notifications.EmailSender
+	appURL           string // Frontend the emailed links point to
+	guard            *ratelimit.LoginGuard
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8,max=72"`
+}
+
+func NewAccountHandler(userRepo *repository.UserRepository, accountTokenRepo *repository.AccountTokenRepository, refreshTokenRepo *repository.RefreshTokenRepository, denylist *auth.Denylist, keys *auth.KeySet, mailer notifications.EmailSender, appURL string, guard *ratelimit.LoginGuard) *AccountHandler {
+	return &AccountHandler{
+		userRepo:         userRepo,
+		accountTokenRepo: accountTokenRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		denylist:         denylist,
+		keys:             keys,
+		mailer:           mailer,
+		appURL:           strings.TrimSuffix(appURL, "/"),
+		guard:            guard,
+	}
+}
+
+// VerifyEmail handles the token of the link emailed on registration. The user's
+// next token refresh lifts the restrictions of unverified accounts.
+func (h *AccountHandler) VerifyEmail(c echo.Context) error {
+	var req VerifyEmailRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	claims, err := auth.ValidateToken(req.Token, h.keys, auth.TokenTypeEmailVerification)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid or expired token")
+	}
+	token, err := h.accountTokenRepo.Consume(models.TokenPurposeVerifyEmail, hashToken(req.Token))
+	if err != nil || token.UserID.Hex() != claims.UserID {
+		return errorResponse(c, http.StatusBadRequest, "Invalid or expired token")
+	}
+
+	if err := h.userRepo.MarkEmailVerified(token.UserID); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to verify email")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "email verified successfully",
+	})
+}
+
+// ResendVerification handles emailing a new verification link to the
+// authenticated user, the previous links stop working
+func (h *AccountHandler) ResendVerification(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.userRepo.GetUserByID(userID)
+	if err != nil {
+		return errorResponse(c, http.StatusNotFound, "User not found")
+	}
+	if user.EmailVerified {
+		return errorResponse(c, http.StatusConflict, "Email is already verified")
+	}
+
+	if err := h.sendVerification(user); err != nil {
+		log.Printf("Error sending verification email to user %s: %v", user.ID.Hex(), err)
+		return errorResponse(c, http.StatusInternalServerError, "Failed to send verification email")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "verification email sent",
+	})
+}
+
+// ForgotPassword handles emailing a password reset link. The response is the
+// same whether the email is registered or not, so it can't be used to find
+// out which emails have an account.
+func (h *AccountHandler) ForgotPassword(c echo.Context) error {
+	var req ForgotPasswordRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.userRepo.GetUserByEmail(strings.TrimSpace(req.Email))
+	if err == nil && !user.Disabled {
+		if err := h.sendPasswordReset(user); err != nil {
+			log.Printf("Error sending password reset email to user %s: %v", user.ID.Hex(), err)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "if the email is registered, a password reset link was sent to it",
+	})
+}
+
+// ResetPassword handles setting a new password with the token of a reset link.
+// Every session of the user is logged out and the account's failed logins are
+// forgotten.
+func (h *AccountHandler) ResetPassword(c echo.Context) error {
+	var req ResetPasswordRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	token, err := h.accountTokenRepo.Consume(models.TokenPurposeResetPassword, hashToken(req.Token))
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid or expired token")
+	}
+
+	user, err := h.userRepo.GetUserByID(token.UserID)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid or expired token")
+	}
+	if user.Disabled {
+		return errorResponse(c, http.StatusForbidden, "Account is disabled")
+	}
+
+	if err := user.HashPassword(req.NewPassword); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to reset password")
+	}
+	if err := h.userRepo.SetPassword(user.ID, user.Password); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to reset password")
+	}
+	// The link was emailed to the user, so following it proves they own the email
+	if err := h.userRepo.MarkEmailVerified(user.ID); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to reset password")
+	}
+
+	if err := revokeSessions(c, user.ID, h.refreshTokenRepo, h.denylist); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to log out sessions")
+	}
+	// The new password ends the lockout of the IP the reset came from
+	h.guard.Succeed(c.Request().Context(), user.Email, c.RealIP())
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "password reset successfully, log in again",
+	})
+}
+
+// sendVerification emails a user the link verifying their email. The token is
+// signed with the JWT keys and stored so it can only be used once.
+func (h *AccountHandler) sendVerification(user *models.User) error {
+	token, claims, err := auth.GenerateToken(user.ID.Hex(), auth.TokenTypeEmailVerification, models.EmailVerificationExpiration, h.keys)
+	if err != nil {
+		return err
+	}
+	accountToken := models.NewAccountToken(models.TokenPurposeVerifyEmail, user.ID, hashToken(token), claims.ExpiresAt.Time)
+	if err := h.accountTokenRepo.Create(accountToken); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening this link within 24 hours:\n\n%s\n\nIf you didn't create an account, ignore this email.",
+		user.Username, h.link("/verify-email", token))
+	return h.mailer.SendEmail(user.Email, "Verify your email address", body)
+}
+
+// sendPasswordReset emails a user a link to choose a new password
+func (h *AccountHandler) sendPasswordReset(user *models.User) error {
+	token, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+	accountToken := models.NewAccountToken(models.TokenPurposeResetPassword, user.ID, hashToken(token), time.Now().Add(models.PasswordResetExpiration))
+	if err := h.accountTokenRepo.Create(accountToken); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nChoose a new password by opening this link within 1 hour:\n\n%s\n\nIf you didn't ask to reset your password, ignore this email.",
+		user.Username, h.link("/reset-password", token))
+	return h.mailer.SendEmail(user.Email, "Reset your password", body)
+}
+
+// link returns the frontend URL handling an emailed token
+func (h *AccountHandler) link(path, token string) string {
+	return h.appURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// newSecretToken returns a random URL safe token
+func newSecretToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken returns the hash stored instead of an emailed token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github/Rubncal04/youtube-premium/auth"
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyHandler lets users create API keys for scripts and integrations, sent
+// in the X-API-Key header instead of a bearer token
+type APIKeyHandler struct {
+	apiKeyRepo *repository.APIKeyRepository
+}
+
+type APIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=read write"`
+}
+
+// APIKeyResponse is returned once, when the key is created: it can't be
+// recovered later since only its hash is stored
+type APIKeyResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+func NewAPIKeyHandler(apiKeyRepo *repository.APIKeyRepository) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyRepo: apiKeyRepo}
+}
+
+// CreateAPIKey handles creating an API key for the authenticated user. Its
+// scopes can't grant more than the role of the user does.
+func (h *APIKeyHandler) CreateAPIKey(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req APIKeyRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	role, _ := c.Get("role").(string)
+	scopes := []string{}
+	for _, scope := range req.Scopes {
+		if !auth.HasPermission(role, auth.Permission(scope)) {
+			return errorResponse(c, http.StatusForbidden, "Your role doesn't grant the "+scope+" scope")
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	apiKey, key, err := models.NewAPIKey(userID, req.Name, scopes)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to create API key")
+	}
+	if err := h.apiKeyRepo.Create(apiKey); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to create API key")
+	}
+
+	return c.JSON(http.StatusCreated, APIKeyResponse{APIKey: *apiKey, Key: key})
+}
+
+// GetAPIKeys handles listing the active API keys of the authenticated user
+func (h *APIKeyHandler) GetAPIKeys(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	keys, err := h.apiKeyRepo.GetByUser(userID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get API keys")
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey handles revoking an API key, requests sending it fail from then on
+func (h *APIKeyHandler) RevokeAPIKey(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid API key ID")
+	}
+
+	if err := h.apiKeyRepo.Revoke(userID, id); err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return errorResponse(c, http.StatusNotFound, "API key not found")
+		}
+		return errorResponse(c, http.StatusInternalServerError, "Failed to revoke API key")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "API key revoked successfully"})
+}
@@ -1,48 +1,74 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github/Rubncal04/youtube-premium/auth"
 	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/middleware"
 	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/ratelimit"
+	"github/Rubncal04/youtube-premium/repository"
 
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
 }
 
 type RegisterRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Name     string `json:"name"`
+	Username string `json:"username" validate:"required,min=3,max=50"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8,max=72"` // bcrypt ignores bytes past 72
+	Name     string `json:"name" validate:"omitempty,max=100"`
 }
 
 type RefreshRequest struct {
-	RefreshToken string `json:"refresh_token"`
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,max=72"`
 }
 
 type AuthResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
 	User         struct {
-		ID       string `json:"id"`
-		Name     string `json:"name"`
-		Username string `json:"username"`
-		Email    string `json:"email"`
-		Role     string `json:"role"`
+		ID               string `json:"id"`
+		Name             string `json:"name"`
+		Username         string `json:"username"`
+		Email            string `json:"email"`
+		EmailVerified    bool   `json:"email_verified"`
+		TwoFactorEnabled bool   `json:"two_factor_enabled"`
+		Role             string `json:"role"`
 	} `json:"user"`
 }
 
-func Register(c echo.Context, mongoRepo *db.MongoRepo) error {
+// TwoFactorChallengeResponse is returned by the login of users with two-factor
+// authentication, instead of the tokens
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int64  `json:"expires_in"` // Seconds until the challenge expires
+}
+
+// Register creates an account and emails the link verifying its email. Until
+// it is followed the account can only read.
+func Register(c echo.Context, mongoRepo *db.MongoRepo, accounts *AccountHandler) error {
 	var req RegisterRequest
-	if err := c.Bind(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	if err := bindRequest(c, &req); err != nil {
+		return err
 	}
 
 	// Check if user already exists
@@ -53,30 +79,44 @@ func Register(c echo.Context, mongoRepo *db.MongoRepo) error {
 	existingUser := &models.User{}
 	_, err := mongoRepo.FindOne("users", filter, existingUser)
 	if err == nil {
-		return echo.NewHTTPError(http.StatusConflict, "user already exists")
+		return errorResponse(c, http.StatusConflict, "User already exists")
 	}
 
 	// Create new user
 	user, err := models.NewUser(req.Username, req.Email, req.Password)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create user")
+		return errorResponse(c, http.StatusInternalServerError, "Failed to create user")
 	}
 
 	// Save user to database
-	_, err = mongoRepo.Create("users", user)
+	result, err := mongoRepo.Create("users", user)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save user")
+		return errorResponse(c, http.StatusInternalServerError, "Failed to save user")
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
+
+	// The account is usable without the email, a new one can be requested later
+	if err := accounts.sendVerification(user); err != nil {
+		log.Printf("Error sending verification email to user %s: %v", user.ID.Hex(), err)
 	}
 
 	return c.JSON(http.StatusCreated, map[string]string{
-		"message": "user created successfully",
+		"message": "user created successfully, check your email to verify it",
 	})
 }
 
-func Login(c echo.Context, mongoRepo *db.MongoRepo, secretKey string) error {
+// Login issues a token pair for valid credentials. The attempts per account and
+// IP are limited by the guard, which locks them out after repeated failures.
+func Login(c echo.Context, mongoRepo *db.MongoRepo, tokens *repository.RefreshTokenRepository, keys *auth.KeySet, guard *ratelimit.LoginGuard) error {
 	var req LoginRequest
-	if err := c.Bind(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	if wait := guard.Check(ctx, req.Email, c.RealIP()); wait > 0 {
+		middleware.SetRetryAfter(c, wait)
+		return errorResponse(c, http.StatusTooManyRequests, "Too many login attempts, try again later")
 	}
 
 	// Find user by email
@@ -84,59 +124,217 @@ func Login(c echo.Context, mongoRepo *db.MongoRepo, secretKey string) error {
 	user := &models.User{}
 	_, err := mongoRepo.FindOne("users", filter, user)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusUnauthorized, "invalid credentials")
+		guard.Fail(ctx, req.Email, c.RealIP())
+		return errorResponse(c, http.StatusUnauthorized, "Invalid credentials")
 	}
 
 	// Check password
 	if err := user.CheckPassword(req.Password); err != nil {
-		return echo.NewHTTPError(http.StatusUnauthorized, "invalid credentials")
+		guard.Fail(ctx, req.Email, c.RealIP())
+		return errorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+	}
+	guard.Succeed(ctx, req.Email, c.RealIP())
+	if user.Disabled {
+		return errorResponse(c, http.StatusForbidden, "Account is disabled")
+	}
+
+	// Users with two-factor authentication get a challenge instead, exchanged
+	// for the tokens at /login/2fa with a code of their authenticator app
+	if user.TwoFactorEnabled {
+		challenge, _, err := auth.GenerateToken(user.ID.Hex(), auth.TokenTypeTwoFactorChallenge, auth.TwoFactorChallengeExpiration, keys)
+		if err != nil {
+			return errorResponse(c, http.StatusInternalServerError, "Failed to generate tokens")
+		}
+		return c.JSON(http.StatusOK, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int64(auth.TwoFactorChallengeExpiration.Seconds()),
+		})
 	}
 
-	// Generate tokens
-	tokenPair, err := auth.GenerateTokenPair(user.ID.Hex(), secretKey)
+	// Generate tokens, starting a new refresh token family
+	tokenPair, err := issueTokens(tokens, user, "", keys)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate tokens")
+		return errorResponse(c, http.StatusInternalServerError, "Failed to generate tokens")
 	}
 
-	// Prepare response
+	return c.JSON(http.StatusOK, newAuthResponse(user, tokenPair))
+}
+
+// newAuthResponse builds the response of a successful login
+func newAuthResponse(user *models.User, tokenPair *auth.TokenPair) AuthResponse {
 	response := AuthResponse{
 		AccessToken:  tokenPair.AccessToken,
 		RefreshToken: tokenPair.RefreshToken,
-		User: struct {
-			ID       string `json:"id"`
-			Name     string `json:"name"`
-			Username string `json:"username"`
-			Email    string `json:"email"`
-			Role     string `json:"role"`
-		}{
-			ID:       user.ID.Hex(),
-			Name:     user.Name,
-			Username: user.Username,
-			Email:    user.Email,
-			Role:     user.Role,
-		},
+		ExpiresIn:    tokenPair.ExpiresIn,
 	}
-
-	return c.JSON(http.StatusOK, response)
+	response.User.ID = user.ID.Hex()
+	response.User.Name = user.Name
+	response.User.Username = user.Username
+	response.User.Email = user.Email
+	response.User.EmailVerified = user.EmailVerified
+	response.User.TwoFactorEnabled = user.TwoFactorEnabled
+	response.User.Role = user.Role
+	return response
 }
 
-func RefreshToken(c echo.Context, secretKey string) error {
+func RefreshToken(c echo.Context, mongoRepo *db.MongoRepo, tokens *repository.RefreshTokenRepository, keys *auth.KeySet) error {
 	var req RefreshRequest
-	if err := c.Bind(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	if err := bindRequest(c, &req); err != nil {
+		return err
 	}
 
 	// Validate refresh token
-	claims, err := auth.ValidateToken(req.RefreshToken, secretKey)
+	claims, err := auth.ValidateToken(req.RefreshToken, keys, auth.TokenTypeRefresh)
+	if err != nil {
+		return errorResponse(c, http.StatusUnauthorized, "Invalid refresh token")
+	}
+	userID, err := primitive.ObjectIDFromHex(claims.UserID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusUnauthorized, "invalid refresh token")
+		return errorResponse(c, http.StatusUnauthorized, "Invalid refresh token")
+	}
+
+	// Reload the user so role changes and disabled accounts apply on refresh
+	user := &models.User{}
+	if _, err := mongoRepo.FindOne("users", bson.M{"_id": userID}, user); err != nil {
+		return errorResponse(c, http.StatusUnauthorized, "Invalid refresh token")
+	}
+	if user.Disabled {
+		return errorResponse(c, http.StatusForbidden, "Account is disabled")
+	}
+
+	// Rotate: the presented token can't be used again
+	if _, err := tokens.Consume(claims.ID); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRefreshTokenReused):
+			log.Printf("Refresh token reuse detected for user %s, revoked family %s", claims.UserID, claims.Family)
+			return errorResponse(c, http.StatusUnauthorized, "Refresh token has already been used, log in again")
+		case errors.Is(err, repository.ErrRefreshTokenNotFound):
+			return errorResponse(c, http.StatusUnauthorized, "Invalid refresh token")
+		default:
+			return errorResponse(c, http.StatusInternalServerError, "Failed to refresh tokens")
+		}
 	}
 
-	// Generate new token pair
-	tokenPair, err := auth.GenerateTokenPair(claims.UserID, secretKey)
+	// Generate new token pair in the same family
+	tokenPair, err := issueTokens(tokens, user, claims.Family, keys)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate tokens")
+		return errorResponse(c, http.StatusInternalServerError, "Failed to generate tokens")
 	}
 
 	return c.JSON(http.StatusOK, tokenPair)
 }
+
+// Logout revokes the session of a refresh token, along with every token rotated
+// from it. The access token sent in the Authorization header, if any, is denied.
+func Logout(c echo.Context, tokens *repository.RefreshTokenRepository, denylist *auth.Denylist, keys *auth.KeySet) error {
+	var req RefreshRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	claims, err := auth.ValidateToken(req.RefreshToken, keys, auth.TokenTypeRefresh)
+	if err != nil {
+		return errorResponse(c, http.StatusUnauthorized, "Invalid refresh token")
+	}
+
+	if err := tokens.RevokeFamily(claims.Family); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to log out")
+	}
+
+	bearer := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+	if accessClaims, err := auth.ValidateToken(bearer, keys, auth.TokenTypeAccess); err == nil && accessClaims.UserID == claims.UserID {
+		if err := denylist.RevokeToken(c.Request().Context(), accessClaims); err != nil {
+			return errorResponse(c, http.StatusInternalServerError, "Failed to log out")
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "logged out successfully",
+	})
+}
+
+// LogoutAll revokes every session of the authenticated user, including the
+// access tokens already issued
+func LogoutAll(c echo.Context, tokens *repository.RefreshTokenRepository, denylist *auth.Denylist) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := revokeSessions(c, userID, tokens, denylist); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to log out")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "logged out of all sessions successfully",
+	})
+}
+
+// ChangePassword replaces the password of the authenticated user and logs out
+// every session
+func ChangePassword(c echo.Context, mongoRepo *db.MongoRepo, tokens *repository.RefreshTokenRepository, denylist *auth.Denylist) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req ChangePasswordRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	user := &models.User{}
+	if _, err := mongoRepo.FindOne("users", bson.M{"_id": userID}, user); err != nil {
+		return errorResponse(c, http.StatusNotFound, "User not found")
+	}
+	if err := user.CheckPassword(req.CurrentPassword); err != nil {
+		return errorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+	}
+
+	if err := user.HashPassword(req.NewPassword); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to update password")
+	}
+	update := bson.M{"$set": bson.M{"password": user.Password, "updated_at": time.Now()}}
+	if err := mongoRepo.UpdateOne("users", bson.M{"_id": userID}, update); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to update password")
+	}
+
+	if err := revokeSessions(c, userID, tokens, denylist); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to log out sessions")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "password updated successfully, log in again",
+	})
+}
+
+// revokeSessions revokes the refresh tokens of a user and denies the access
+// tokens issued to them so far
+func revokeSessions(c echo.Context, userID primitive.ObjectID, tokens *repository.RefreshTokenRepository, denylist *auth.Denylist) error {
+	if err := tokens.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	return denylist.RevokeUser(c.Request().Context(), userID.Hex())
+}
+
+// JWKS publishes the public keys verifying our tokens
+func JWKS(c echo.Context, keys *auth.KeySet) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, keys.JWKS())
+}
+
+// issueTokens generates a token pair for a user and stores its refresh token so
+// it can be rotated and revoked
+func issueTokens(tokens *repository.RefreshTokenRepository, user *models.User, family string, keys *auth.KeySet) (*auth.TokenPair, error) {
+	tokenPair, refreshClaims, err := auth.GenerateTokenPair(user.ID.Hex(), user.Role, user.EmailVerified, family, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := models.NewRefreshToken(refreshClaims.ID, refreshClaims.Family, user.ID, refreshClaims.ExpiresAt.Time)
+	if err := tokens.Create(refreshToken); err != nil {
+		return nil, err
+	}
+	return tokenPair, nil
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github/Rubncal04/youtube-premium/authz"
+	"github/Rubncal04/youtube-premium/middleware"
+	"github/Rubncal04/youtube-premium/models"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// currentUserID returns the authenticated user set by middleware.AuthMiddleware.
+// The returned error is rendered by HTTPErrorHandler.
+func currentUserID(c echo.Context) (primitive.ObjectID, error) {
+	userID, ok := c.Get("user_id").(primitive.ObjectID)
+	if !ok {
+		return primitive.NilObjectID, echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+	return userID, nil
+}
+
+// contextClient returns the client loaded and authorized by middleware.LoadClient,
+// which every route with a client ID parameter runs
+func contextClient(c echo.Context) *models.Client {
+	return c.Get(middleware.ClientContextKey).(*models.Client)
+}
+
+// resolveOwner returns the owner whose data a listing shows: the owner_id query
+// parameter when a collaborator sets it, the authenticated user otherwise
+func resolveOwner(c echo.Context, service *authz.Service, action authz.Action) (primitive.ObjectID, error) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	ownerParam := c.QueryParam("owner_id")
+	if ownerParam == "" {
+		return userID, nil
+	}
+	ownerID, err := primitive.ObjectIDFromHex(ownerParam)
+	if err != nil {
+		return primitive.NilObjectID, echo.NewHTTPError(http.StatusBadRequest, "Invalid owner ID")
+	}
+
+	switch err := service.Authorize(userID, ownerID, action); {
+	case err == nil:
+		return ownerID, nil
+	case errors.Is(err, authz.ErrInsufficientScope):
+		return primitive.NilObjectID, echo.NewHTTPError(http.StatusForbidden, "Your access to these clients doesn't allow this action")
+	default:
+		return primitive.NilObjectID, echo.NewHTTPError(http.StatusNotFound, "Owner not found")
+	}
+}
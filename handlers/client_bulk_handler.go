@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/notifications"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxBulkTargets limits how many clients a single bulk operation can change
+const maxBulkTargets = 500
+
+var errTooManyTargets = fmt.Errorf("Too many clients: the limit is %d", maxBulkTargets)
+
+type ClientBulkHandler struct {
+	clientRepo *repository.ClientRepository
+	ledgerRepo *repository.LedgerRepository
+	notifier   notifications.NotificationService
+}
+
+// BulkRequest selects the clients of a bulk operation, either by ID, with the
+// same filters as the client listing or all of them. Exactly one of them must be
+// sent, so an empty filter doesn't select every client by mistake.
+type BulkRequest struct {
+	IDs    []string          `json:"ids"`
+	Filter *BulkClientFilter `json:"filter"`
+	All    bool              `json:"all"`
+}
+
+type BulkClientFilter struct {
+	Status   models.ClientStatus `json:"status"`
+	DayFrom  int                 `json:"day_from"`
+	DayTo    int                 `json:"day_to"`
+	PaidFrom *time.Time          `json:"paid_from"`
+	PaidTo   *time.Time          `json:"paid_to"`
+	Search   string              `json:"q"`
+}
+
+// isEmpty reports whether the filter sets none of its fields
+func (f BulkClientFilter) isEmpty() bool {
+	return f.Status == "" && f.DayFrom == 0 && f.DayTo == 0 && f.PaidFrom == nil && f.PaidTo == nil && f.Search == ""
+}
+
+type BulkUpdateRequest struct {
+	BulkRequest
+	DayToPay *int `json:"day_to_pay" validate:"required,gte=1,lte=31"`
+}
+
+type BulkStatusRequest struct {
+	BulkRequest
+	Action string `json:"action" validate:"required,oneof=pause resume cancel archive"`
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+type BulkMessageRequest struct {
+	BulkRequest
+	Message string `json:"message" validate:"required,max=1600"` // WhatsApp's message limit
+}
+
+// BulkItemResult is the outcome of a bulk operation for a single client
+type BulkItemResult struct {
+	ID    string `json:"id"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type BulkResult struct {
+	Total     int              `json:"total"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkItemResult `json:"results"`
+}
+
+func NewClientBulkHandler(clientRepo *repository.ClientRepository, ledgerRepo *repository.LedgerRepository, notifier notifications.NotificationService) *ClientBulkHandler {
+	return &ClientBulkHandler{
+		clientRepo: clientRepo,
+		ledgerRepo: ledgerRepo,
+		notifier:   notifier,
+	}
+}
+
+// BulkUpdate handles setting the same payment day on several clients
+func (h *ClientBulkHandler) BulkUpdate(c echo.Context) error {
+	var request BulkUpdateRequest
+	if err := bindRequest(c, &request); err != nil {
+		return err
+	}
+
+	clients, result, status, err := h.resolveTargets(c, request.BulkRequest)
+	if err != nil {
+		return errorResponse(c, status, err.Error())
+	}
+
+	if len(clients) > 0 {
+		updateData := bson.M{
+			"day_to_pay": *request.DayToPay,
+			"updated_at": time.Now(),
+		}
+		if err := h.clientRepo.UpdateMany(clients, updateData); err != nil {
+			return errorResponse(c, http.StatusInternalServerError, "Failed to update clients")
+		}
+	}
+
+	for _, client := range clients {
+		result.add(client.ID.Hex(), nil)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// BulkChangeStatus handles pausing, resuming, cancelling or archiving several
+// clients. Each client is moved on its own, so invalid transitions only fail
+// for the clients they apply to.
+func (h *ClientBulkHandler) BulkChangeStatus(c echo.Context) error {
+	var request BulkStatusRequest
+	if err := bindRequest(c, &request); err != nil {
+		return err
+	}
+
+	var target func(*models.Client) (models.ClientStatus, error)
+	switch request.Action {
+	case "pause":
+		target = func(*models.Client) (models.ClientStatus, error) { return models.ClientStatusSuspended, nil }
+	case "resume":
+		target = func(client *models.Client) (models.ClientStatus, error) {
+			balance, err := h.ledgerRepo.GetBalance(client.ID)
+			if err != nil {
+				return "", errors.New("failed to get balance")
+			}
+			return models.ClientStatusForBalance(balance), nil
+		}
+	case "cancel":
+		target = func(*models.Client) (models.ClientStatus, error) { return models.ClientStatusCancelled, nil }
+	case "archive":
+		target = func(*models.Client) (models.ClientStatus, error) { return models.ClientStatusArchived, nil }
+	default:
+		return errorResponse(c, http.StatusBadRequest, "Invalid action: must be pause, resume, cancel or archive")
+	}
+
+	clients, result, status, err := h.resolveTargets(c, request.BulkRequest)
+	if err != nil {
+		return errorResponse(c, status, err.Error())
+	}
+
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+	for i := range clients {
+		client := &clients[i]
+		newStatus, err := target(client)
+		if err == nil {
+			err = h.clientRepo.ChangeStatus(client, newStatus, request.Reason, &userID)
+		}
+		result.add(client.ID.Hex(), err)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// BulkMessage handles sending an ad-hoc WhatsApp message to several clients.
+// Messages are queued and sent in the background, the result reports which
+// ones were queued.
+func (h *ClientBulkHandler) BulkMessage(c echo.Context) error {
+	var request BulkMessageRequest
+	if err := bindRequest(c, &request); err != nil {
+		return err
+	}
+	if strings.TrimSpace(request.Message) == "" {
+		return errorResponse(c, http.StatusBadRequest, "Message can't be blank")
+	}
+
+	clients, result, status, err := h.resolveTargets(c, request.BulkRequest)
+	if err != nil {
+		return errorResponse(c, status, err.Error())
+	}
+
+	for _, client := range clients {
+		result.add(client.ID.Hex(), h.notifier.SendReminder(client, request.Message))
+	}
+	return c.JSON(http.StatusAccepted, result)
+}
+
+// BulkDelete handles moving several clients to the trash
+func (h *ClientBulkHandler) BulkDelete(c echo.Context) error {
+	var request BulkRequest
+	if err := bindRequest(c, &request); err != nil {
+		return err
+	}
+
+	clients, result, status, err := h.resolveTargets(c, request)
+	if err != nil {
+		return errorResponse(c, status, err.Error())
+	}
+
+	for i := range clients {
+		result.add(clients[i].ID.Hex(), h.clientRepo.Delete(&clients[i]))
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// resolveTargets loads the clients selected by a bulk request. Requested IDs that
+// are invalid, missing or owned by another user are reported as failed items of
+// the returned result, clients of other users as not found. When the targets
+// can't be resolved it returns the error and the status to respond with.
+func (h *ClientBulkHandler) resolveTargets(c echo.Context, request BulkRequest) ([]models.Client, *BulkResult, int, error) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return nil, nil, http.StatusUnauthorized, errors.New("Unauthorized")
+	}
+
+	selectors := 0
+	for _, set := range []bool{len(request.IDs) > 0, request.Filter != nil, request.All} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return nil, nil, http.StatusBadRequest, errors.New("Exactly one of ids, filter or all must be sent")
+	}
+	if request.Filter != nil && request.Filter.isEmpty() {
+		return nil, nil, http.StatusBadRequest, errors.New("Filter must set at least one field, send all: true to select every client")
+	}
+
+	result := &BulkResult{Results: []BulkItemResult{}}
+
+	if request.Filter != nil || request.All {
+		filter := repository.ClientFilter{}
+		if request.Filter != nil {
+			filter = repository.ClientFilter{
+				Status:   request.Filter.Status,
+				DayFrom:  request.Filter.DayFrom,
+				DayTo:    request.Filter.DayTo,
+				PaidFrom: request.Filter.PaidFrom,
+				PaidTo:   request.Filter.PaidTo,
+				Search:   request.Filter.Search,
+			}
+		}
+
+		clients := []models.Client{}
+		err = h.clientRepo.Each(userID, filter, repository.ListQuery{SortField: "name"}, func(client models.Client) error {
+			if len(clients) == maxBulkTargets {
+				return errTooManyTargets
+			}
+			clients = append(clients, client)
+			return nil
+		})
+		if errors.Is(err, errTooManyTargets) {
+			return nil, nil, http.StatusBadRequest, err
+		}
+		if err != nil {
+			return nil, nil, http.StatusInternalServerError, errors.New("Failed to get clients")
+		}
+		return clients, result, 0, nil
+	}
+
+	if len(request.IDs) > maxBulkTargets {
+		return nil, nil, http.StatusBadRequest, errTooManyTargets
+	}
+
+	clients := make([]models.Client, 0, len(request.IDs))
+	seen := make(map[string]bool, len(request.IDs))
+	for _, id := range request.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if _, err := primitive.ObjectIDFromHex(id); err != nil {
+			result.add(id, errors.New("Invalid client ID"))
+			continue
+		}
+
+		client, err := h.clientRepo.GetByID(id)
+		if err != nil || client.UserID != userID {
+			result.add(id, errors.New("Client not found"))
+			continue
+		}
+		clients = append(clients, *client)
+	}
+
+	return clients, result, 0, nil
+}
+
+// add records the outcome of the operation for a client
+func (r *BulkResult) add(id string, err error) {
+	item := BulkItemResult{ID: id, OK: err == nil}
+	if err != nil {
+		item.Error = err.Error()
+		r.Failed++
+	} else {
+		r.Succeeded++
+	}
+	r.Total++
+	r.Results = append(r.Results, item)
+}
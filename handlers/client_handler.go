@@ -1,43 +1,57 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github/Rubncal04/youtube-premium/authz"
 	"github/Rubncal04/youtube-premium/models"
 	"github/Rubncal04/youtube-premium/repository"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type ClientHandler struct {
 	clientRepo *repository.ClientRepository
+	ledgerRepo *repository.LedgerRepository
+	authz      *authz.Service
 }
 
 type ClientRequest struct {
-	Name      string `json:"name"`
-	CellPhone string `json:"cell_phone"`
-	DayToPay  int    `json:"day_to_pay"`
+	Name      string `json:"name" validate:"required,max=100"`
+	CellPhone string `json:"cell_phone" validate:"required,min=7,max=20"`
+	DayToPay  int    `json:"day_to_pay" validate:"required,gte=1,lte=31"`
 }
 
-func NewClientHandler(clientRepo *repository.ClientRepository) *ClientHandler {
+type StatusChangeRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+func NewClientHandler(clientRepo *repository.ClientRepository, ledgerRepo *repository.LedgerRepository, authzService *authz.Service) *ClientHandler {
 	return &ClientHandler{
 		clientRepo: clientRepo,
+		ledgerRepo: ledgerRepo,
+		authz:      authzService,
 	}
 }
 
 // CreateClient handles the creation of a new client
 func (h *ClientHandler) CreateClient(c echo.Context) error {
 	var clientRequest ClientRequest
-	if err := c.Bind(&clientRequest); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	if err := bindRequest(c, &clientRequest); err != nil {
+		return err
 	}
 
 	// Get user ID from context (set by auth middleware)
-	userID, ok := c.Get("user_id").(primitive.ObjectID)
-	if !ok {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
 	}
 
 	client := models.NewClient(userID, clientRequest.Name, clientRequest.CellPhone, clientRequest.DayToPay)
@@ -45,71 +59,78 @@ func (h *ClientHandler) CreateClient(c echo.Context) error {
 	// Save client to database
 	newClient, err := h.clientRepo.Create(*client)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create client"})
+		return errorResponse(c, http.StatusInternalServerError, "Failed to create client")
 	}
 
 	return c.JSON(http.StatusCreated, newClient)
 }
 
-// GetClients handles getting all clients for the authenticated user
+// GetClients handles listing the clients of the authenticated user, or of the
+// owner given in owner_id to a collaborator, with filters, sorting and cursor
+// pagination
 func (h *ClientHandler) GetClients(c echo.Context) error {
-	userID, ok := c.Get("user_id").(primitive.ObjectID)
-	if !ok {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	ownerID, err := resolveOwner(c, h.authz, authz.ActionRead)
+	if err != nil {
+		return err
 	}
 
-	clients, err := h.clientRepo.GetAll(userID)
+	filter, err := parseClientFilter(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get clients"})
+		return errorResponse(c, http.StatusBadRequest, err.Error())
 	}
+	query := parseListQuery(c, repository.ClientSortFields, "name", false)
 
-	return c.JSON(http.StatusOK, clients)
-}
-
-// GetClient handles getting a specific client by ID
-func (h *ClientHandler) GetClient(c echo.Context) error {
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	page, err := h.clientRepo.List(ownerID, filter, query)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid client ID"})
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return errorResponse(c, http.StatusBadRequest, "Invalid cursor")
+		}
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get clients")
 	}
 
-	client, err := h.clientRepo.GetByID(id.Hex())
-	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Client not found"})
+	return c.JSON(http.StatusOK, page)
+}
+
+// parseClientFilter reads the filters of the client listing from the query string
+func parseClientFilter(c echo.Context) (repository.ClientFilter, error) {
+	filter := repository.ClientFilter{
+		Status: models.ClientStatus(c.QueryParam("status")),
+		Search: c.QueryParam("q"),
 	}
 
-	// Verify that the client belongs to the authenticated user
-	userID, ok := c.Get("user_id").(primitive.ObjectID)
-	if !ok || client.UserID != userID {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	var err error
+	if filter.DayFrom, err = parseIntParam(c, "day_from"); err != nil {
+		return filter, err
 	}
+	if filter.DayTo, err = parseIntParam(c, "day_to"); err != nil {
+		return filter, err
+	}
+	if filter.PaidFrom, err = parseDateParam(c, "paid_from", false); err != nil {
+		return filter, err
+	}
+	if filter.PaidTo, err = parseDateParam(c, "paid_to", true); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// GetClient handles getting a specific client by ID
+func (h *ClientHandler) GetClient(c echo.Context) error {
+	client := contextClient(c)
 
+	c.Response().Header().Set("ETag", clientETag(client))
 	return c.JSON(http.StatusOK, client)
 }
 
 // UpdateClient handles updating a client
 func (h *ClientHandler) UpdateClient(c echo.Context) error {
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid client ID"})
-	}
-
-	// Get existing client
-	client, err := h.clientRepo.GetByID(id.Hex())
-	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Client not found"})
-	}
-
-	// Verify ownership
-	userID, ok := c.Get("user_id").(primitive.ObjectID)
-	if !ok || client.UserID != userID {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-	}
+	client := contextClient(c)
 
 	// Bind the update request to a new struct
 	var updateRequest ClientRequest
-	if err := c.Bind(&updateRequest); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	if err := bindRequest(c, &updateRequest); err != nil {
+		return err
 	}
 
 	// Update client fields
@@ -120,39 +141,231 @@ func (h *ClientHandler) UpdateClient(c echo.Context) error {
 		"updated_at": time.Now(),
 	}
 
-	if err := h.clientRepo.Update(id.Hex(), updateData); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update client"})
+	if err := h.clientRepo.Update(client, updateData); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to update client")
 	}
 
 	// Get the updated client
-	updatedClient, err := h.clientRepo.GetByID(id.Hex())
+	updatedClient, err := h.clientRepo.GetByID(client.ID.Hex())
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get updated client"})
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get updated client")
 	}
 
+	c.Response().Header().Set("ETag", clientETag(updatedClient))
 	return c.JSON(http.StatusOK, updatedClient)
 }
 
+// PatchClient handles partially updating a client with a JSON Merge Patch
+// (RFC 7396): only the fields present in the body change. The If-Match header
+// must carry the ETag of the client being modified, so concurrent edits are
+// rejected instead of overwriting each other.
+func (h *ClientHandler) PatchClient(c echo.Context) error {
+	client := contextClient(c)
+
+	ifMatch := c.Request().Header.Get("If-Match")
+	if ifMatch == "" {
+		return errorResponse(c, http.StatusPreconditionRequired, "If-Match header is required")
+	}
+	// "*" matches whatever version is stored, not the one the client was loaded at
+	version := repository.AnyVersion
+	if ifMatch != "*" {
+		var err error
+		if version, err = parseClientETag(ifMatch); err != nil {
+			return errorResponse(c, http.StatusBadRequest, "Invalid If-Match header")
+		}
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(c.Request().Body).Decode(&patch); err != nil || patch == nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	updateData, fieldErrors := clientPatchUpdate(patch)
+	if len(fieldErrors) > 0 {
+		return &ValidationError{Fields: fieldErrors}
+	}
+	if len(updateData) == 0 {
+		c.Response().Header().Set("ETag", clientETag(client))
+		return c.JSON(http.StatusOK, client)
+	}
+	updateData["updated_at"] = time.Now()
+
+	if err := h.clientRepo.Patch(client, updateData, version); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return errorResponse(c, http.StatusPreconditionFailed, "Client was modified by another request, reload it and try again")
+		}
+		return errorResponse(c, http.StatusInternalServerError, "Failed to update client")
+	}
+
+	updatedClient, err := h.clientRepo.GetByID(client.ID.Hex())
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get updated client")
+	}
+
+	c.Response().Header().Set("ETag", clientETag(updatedClient))
+	return c.JSON(http.StatusOK, updatedClient)
+}
+
+// clientPatchUpdate validates the fields of a merge patch and converts them to a
+// $set document. Name and cell phone can't be removed, so null is rejected.
+func clientPatchUpdate(patch map[string]json.RawMessage) (bson.M, []FieldError) {
+	updateData := bson.M{}
+	fieldErrors := []FieldError{}
+
+	for field, raw := range patch {
+		isNull := string(raw) == "null"
+
+		switch field {
+		case "name", "cell_phone":
+			var value string
+			if isNull || json.Unmarshal(raw, &value) != nil || strings.TrimSpace(value) == "" {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be a non-empty string"})
+				continue
+			}
+			updateData[field] = strings.TrimSpace(value)
+		case "day_to_pay":
+			var value int
+			if isNull || json.Unmarshal(raw, &value) != nil || value < 1 || value > 31 {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be an integer between 1 and 31"})
+				continue
+			}
+			updateData[field] = value
+		default:
+			fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "is unknown or read-only"})
+		}
+	}
+
+	sort.Slice(fieldErrors, func(i, j int) bool { return fieldErrors[i].Field < fieldErrors[j].Field })
+	return updateData, fieldErrors
+}
+
+// clientETag returns the entity tag of a client's current version
+func clientETag(client *models.Client) string {
+	return fmt.Sprintf("\"%d\"", client.Version)
+}
+
+// parseClientETag reads the version from an entity tag created by clientETag
+func parseClientETag(etag string) (int64, error) {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	return strconv.ParseInt(strings.Trim(etag, "\""), 10, 64)
+}
+
 func (h *ClientHandler) DeleteClient(c echo.Context) error {
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	client := contextClient(c)
+
+	if err := h.clientRepo.Delete(client); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to delete client")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Client deleted successfully"})
+}
+
+// GetDeletedClients handles listing the clients in the trash of the authenticated user
+func (h *ClientHandler) GetDeletedClients(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	clients, err := h.clientRepo.GetDeleted(userID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get deleted clients")
+	}
+
+	return c.JSON(http.StatusOK, clients)
+}
+
+// RestoreClient handles taking a client out of the trash
+func (h *ClientHandler) RestoreClient(c echo.Context) error {
+	client := contextClient(c)
+
+	if err := h.clientRepo.Restore(client); err != nil {
+		if errors.Is(err, repository.ErrClientPurging) {
+			return errorResponse(c, http.StatusConflict, "Client is being purged and can't be restored")
+		}
+		return errorResponse(c, http.StatusInternalServerError, "Failed to restore client")
+	}
+
+	return c.JSON(http.StatusOK, client)
+}
+
+// PauseClient handles suspending a client: it stops being charged and reminded
+func (h *ClientHandler) PauseClient(c echo.Context) error {
+	return h.changeStatus(c, func(*models.Client) (models.ClientStatus, error) {
+		return models.ClientStatusSuspended, nil
+	})
+}
+
+// ResumeClient handles reactivating a paused or cancelled client, its status
+// depends on whether its balance covers what it owes
+func (h *ClientHandler) ResumeClient(c echo.Context) error {
+	return h.changeStatus(c, func(client *models.Client) (models.ClientStatus, error) {
+		balance, err := h.ledgerRepo.GetBalance(client.ID)
+		if err != nil {
+			return "", err
+		}
+		return models.ClientStatusForBalance(balance), nil
+	})
+}
+
+// CancelClient handles cancelling a client without deleting its payment history
+func (h *ClientHandler) CancelClient(c echo.Context) error {
+	return h.changeStatus(c, func(*models.Client) (models.ClientStatus, error) {
+		return models.ClientStatusCancelled, nil
+	})
+}
+
+// ArchiveClient handles archiving a cancelled client
+func (h *ClientHandler) ArchiveClient(c echo.Context) error {
+	return h.changeStatus(c, func(*models.Client) (models.ClientStatus, error) {
+		return models.ClientStatusArchived, nil
+	})
+}
+
+// GetStatusHistory handles getting the status changes of a client
+func (h *ClientHandler) GetStatusHistory(c echo.Context) error {
+	client := contextClient(c)
+
+	history, err := h.clientRepo.GetStatusHistory(client.ID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid client ID"})
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get status history")
 	}
 
-	client, err := h.clientRepo.GetByID(id.Hex())
+	return c.JSON(http.StatusOK, history)
+}
+
+// changeStatus moves the client loaded by the route to the status returned by
+// target, recording the reason sent in the request body
+func (h *ClientHandler) changeStatus(c echo.Context, target func(*models.Client) (models.ClientStatus, error)) error {
+	client := contextClient(c)
+	userID, err := currentUserID(c)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Client not found"})
+		return err
 	}
 
-	userID, ok := c.Get("user_id").(primitive.ObjectID)
-	if !ok || client.UserID != userID {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	var request StatusChangeRequest
+	if err := bindRequest(c, &request); err != nil {
+		return err
 	}
 
-	err = h.clientRepo.Delete(id.Hex())
+	newStatus, err := target(client)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete client"})
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get balance")
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"message": "Client deleted successfully"})
+	err = h.clientRepo.ChangeStatus(client, newStatus, request.Reason, &userID)
+	switch {
+	case errors.Is(err, repository.ErrStatusConflict), errors.Is(err, models.ErrInvalidTransition), errors.Is(err, models.ErrInvalidClientStatus):
+		return errorResponse(c, http.StatusConflict, err.Error())
+	case err != nil:
+		return errorResponse(c, http.StatusInternalServerError, "Failed to change client status")
+	}
+
+	updatedClient, err := h.clientRepo.GetByID(client.ID.Hex())
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get updated client")
+	}
+
+	c.Response().Header().Set("ETag", clientETag(updatedClient))
+	return c.JSON(http.StatusOK, updatedClient)
 }
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/notifications"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CollaboratorHandler lets owners invite other users to help them manage their
+// clients, and those users accept the invitations
+type CollaboratorHandler struct {
+	collaboratorRepo *repository.CollaboratorRepository
+	userRepo         *repository.UserRepository
+	mailer           notifications.EmailSender
+	appURL           string // Frontend the emailed links point to
+}
+
+type InvitationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Scope string `json:"scope" validate:"required,oneof=read payments"`
+}
+
+type GrantScopeRequest struct {
+	Scope string `json:"scope" validate:"required,oneof=read payments"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func NewCollaboratorHandler(collaboratorRepo *repository.CollaboratorRepository, userRepo *repository.UserRepository, mailer notifications.EmailSender, appURL string) *CollaboratorHandler {
+	return &CollaboratorHandler{
+		collaboratorRepo: collaboratorRepo,
+		userRepo:         userRepo,
+		mailer:           mailer,
+		appURL:           strings.TrimSuffix(appURL, "/"),
+	}
+}
+
+// CreateInvitation handles inviting a user by email. The invitee is emailed the
+// link to accept it; the token is not stored, so it can't be sent again.
+func (h *CollaboratorHandler) CreateInvitation(c echo.Context) error {
+	ownerID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req InvitationRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	owner, err := h.userRepo.GetUserByID(ownerID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get user")
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == strings.ToLower(owner.Email) {
+		return errorResponse(c, http.StatusBadRequest, "You can't invite yourself")
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to create invitation")
+	}
+	invitation := models.NewInvitation(ownerID, email, models.GrantScope(req.Scope), hashToken(token))
+	if err := h.collaboratorRepo.CreateInvitation(invitation); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to create invitation")
+	}
+
+	body := fmt.Sprintf("Hi,\n\n%s invited you to help manage their YouTube Premium clients. Accept the invitation by opening this link within 7 days:\n\n%s\n\nIf you don't have an account yet, register with this email address first.",
+		owner.Username, h.appURL+"/accept-invitation?token="+url.QueryEscape(token))
+	if err := h.mailer.SendEmail(email, "You were invited to collaborate", body); err != nil {
+		log.Printf("Error sending invitation email for invitation %s: %v", invitation.ID.Hex(), err)
+		if err := h.collaboratorRepo.DeleteInvitation(ownerID, invitation.ID); err != nil {
+			log.Printf("Error deleting unsent invitation %s: %v", invitation.ID.Hex(), err)
+		}
+		return errorResponse(c, http.StatusInternalServerError, "Failed to send invitation email")
+	}
+
+	return c.JSON(http.StatusCreated, invitation)
+}
+
+// GetInvitations handles listing the pending invitations of the authenticated owner
+func (h *CollaboratorHandler) GetInvitations(c echo.Context) error {
+	ownerID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	invitations, err := h.collaboratorRepo.GetPendingInvitations(ownerID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get invitations")
+	}
+
+	return c.JSON(http.StatusOK, invitations)
+}
+
+// DeleteInvitation handles revoking a pending invitation
+func (h *CollaboratorHandler) DeleteInvitation(c echo.Context) error {
+	ownerID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid invitation ID")
+	}
+
+	if err := h.collaboratorRepo.DeleteInvitation(ownerID, id); err != nil {
+		if errors.Is(err, repository.ErrInvitationNotFound) {
+			return errorResponse(c, http.StatusNotFound, "Invitation not found")
+		}
+		return errorResponse(c, http.StatusInternalServerError, "Failed to delete invitation")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Invitation deleted successfully"})
+}
+
+// AcceptInvitation handles accepting an invitation sent to the authenticated
+// user's verified email, granting them access to the owner's clients
+func (h *CollaboratorHandler) AcceptInvitation(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req AcceptInvitationRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	invitation, err := h.collaboratorRepo.GetInvitationByTokenHash(hashToken(req.Token))
+	if err != nil {
+		return errorResponse(c, http.StatusNotFound, "Invitation not found or expired")
+	}
+
+	user, err := h.userRepo.GetUserByID(userID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get user")
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return errorResponse(c, http.StatusForbidden, "The invitation was sent to another email")
+	}
+	// Only a verified email proves the user received the invitation
+	if !user.EmailVerified {
+		return errorResponse(c, http.StatusForbidden, "Verify your email before accepting invitations")
+	}
+
+	grant, err := h.collaboratorRepo.AcceptInvitation(invitation, user)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvitationNotFound) {
+			return errorResponse(c, http.StatusNotFound, "Invitation not found or expired")
+		}
+		return errorResponse(c, http.StatusInternalServerError, "Failed to accept invitation")
+	}
+
+	return c.JSON(http.StatusOK, grant)
+}
+
+// GetCollaborators handles listing the users the authenticated owner gave access to
+func (h *CollaboratorHandler) GetCollaborators(c echo.Context) error {
+	ownerID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	grants, err := h.collaboratorRepo.GetGrantsByOwner(ownerID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get collaborators")
+	}
+
+	return c.JSON(http.StatusOK, grants)
+}
+
+// UpdateCollaborator handles changing the scope of a collaborator
+func (h *CollaboratorHandler) UpdateCollaborator(c echo.Context) error {
+	grant, err := h.ownGrant(c)
+	if err != nil {
+		return err
+	}
+
+	var req GrantScopeRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.collaboratorRepo.UpdateGrantScope(grant.ID, models.GrantScope(req.Scope)); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to update collaborator")
+	}
+
+	grant.Scope = models.GrantScope(req.Scope)
+	return c.JSON(http.StatusOK, grant)
+}
+
+// DeleteCollaborator handles removing the access of a collaborator
+func (h *CollaboratorHandler) DeleteCollaborator(c echo.Context) error {
+	grant, err := h.ownGrant(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.collaboratorRepo.DeleteGrant(grant.ID); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to remove collaborator")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Collaborator removed successfully"})
+}
+
+// GetSharedOwners handles listing the owners that gave the authenticated user
+// access, their IDs are the owner_id accepted by the client and payment listings
+func (h *CollaboratorHandler) GetSharedOwners(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	grants, err := h.collaboratorRepo.GetGrantsByCollaborator(userID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get shared access")
+	}
+
+	return c.JSON(http.StatusOK, grants)
+}
+
+// ownGrant loads the grant of the :id parameter given by the authenticated owner
+func (h *CollaboratorHandler) ownGrant(c echo.Context) (*models.Grant, error) {
+	ownerID, err := currentUserID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid collaborator ID")
+	}
+
+	grant, err := h.collaboratorRepo.GetGrantByID(ownerID, id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Collaborator not found")
+	}
+	return grant, nil
+}
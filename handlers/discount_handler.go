@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github/Rubncal04/youtube-premium/billing"
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type DiscountHandler struct {
+	discountRepo *repository.DiscountRepository
+	clientRepo   *repository.ClientRepository
+	ledgerRepo   *repository.LedgerRepository
+	billing      *billing.Service
+}
+
+type DiscountRequest struct {
+	ClientID    string              `json:"client_id"` // Empty to apply the discount to every client
+	Type        models.DiscountType `json:"type"`
+	Value       float64             `json:"value"`
+	Cycles      int                 `json:"cycles"`
+	Description string              `json:"description"`
+}
+
+type AmountDueResponse struct {
+	Period       string                   `json:"period"`
+	BaseAmount   float64                  `json:"base_amount"`
+	Discounts    []models.AppliedDiscount `json:"discounts"`
+	PeriodAmount float64                  `json:"period_amount"` // Charge of the current period after discounts
+	Charged      bool                     `json:"charged"`       // Whether the current period was already charged
+	LateFees     float64                  `json:"late_fees"`     // Late fees of the current period, already part of the balance
+	Balance      float64                  `json:"balance"`
+	AmountDue    float64                  `json:"amount_due"`
+}
+
+func NewDiscountHandler(discountRepo *repository.DiscountRepository, clientRepo *repository.ClientRepository, ledgerRepo *repository.LedgerRepository, billingService *billing.Service) *DiscountHandler {
+	return &DiscountHandler{
+		discountRepo: discountRepo,
+		clientRepo:   clientRepo,
+		ledgerRepo:   ledgerRepo,
+		billing:      billingService,
+	}
+}
+
+// CreateDiscount handles the creation of a discount for one client or for the whole plan
+func (h *DiscountHandler) CreateDiscount(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var request DiscountRequest
+	if err := bindRequest(c, &request); err != nil {
+		return err
+	}
+
+	var clientID *primitive.ObjectID
+	if request.ClientID != "" {
+		client, err := h.clientRepo.GetByID(request.ClientID)
+		if err != nil {
+			return errorResponse(c, http.StatusNotFound, "Client not found")
+		}
+		if client.UserID != userID {
+			return errorResponse(c, http.StatusNotFound, "Client not found")
+		}
+		clientID = &client.ID
+	}
+
+	discount := models.NewDiscount(userID, clientID, request.Type, request.Value, request.Cycles, request.Description, userID)
+	if err := h.discountRepo.Create(discount); err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, discount)
+}
+
+// GetDiscounts handles getting all discounts of the authenticated user
+func (h *DiscountHandler) GetDiscounts(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	discounts, err := h.discountRepo.GetByUserID(userID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get discounts")
+	}
+
+	return c.JSON(http.StatusOK, discounts)
+}
+
+// DeleteDiscount handles deactivating a discount, keeping it for auditing
+func (h *DiscountHandler) DeleteDiscount(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	// Discounts of other users are reported as missing, not hinting they exist
+	discount, err := h.discountRepo.GetByID(c.Param("id"))
+	if err != nil || discount.UserID != userID {
+		return errorResponse(c, http.StatusNotFound, "Discount not found")
+	}
+
+	if err := h.discountRepo.Deactivate(discount.ID, userID); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to deactivate discount")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Discount deactivated successfully"})
+}
+
+// GetAmountDue handles computing what a client owes for the current period,
+// priced as the period is charged: the owner's price configuration with the
+// client's discounts applied
+func (h *DiscountHandler) GetAmountDue(c echo.Context) error {
+	client := contextClient(c)
+
+	period := models.BillingPeriod(time.Now())
+	prices, err := h.billing.Price(*client, []string{period})
+	if err != nil {
+		if errors.Is(err, billing.ErrNoPrice) {
+			return errorResponse(c, http.StatusNotFound, "Price configuration not found")
+		}
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get discounts")
+	}
+	price := prices[0]
+
+	charged, err := h.ledgerRepo.HasCharge(client.ID, period)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get ledger")
+	}
+
+	balance, err := h.ledgerRepo.GetBalance(client.ID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get balance")
+	}
+
+	lateFees, err := h.ledgerRepo.GetLateFeesTotal(client.ID, period)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get late fees")
+	}
+
+	// Once charged, the period amount is already part of the balance
+	amountDue := -balance
+	if !charged && !client.IsPaidFor(time.Now()) {
+		amountDue += price.Amount
+	}
+
+	return c.JSON(http.StatusOK, AmountDueResponse{
+		Period:       period,
+		BaseAmount:   price.Base,
+		Discounts:    price.Discounts,
+		PeriodAmount: price.Amount,
+		Charged:      charged,
+		LateFees:     lateFees,
+		Balance:      balance,
+		AmountDue:    math.Max(amountDue, 0),
+	})
+}
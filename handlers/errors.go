@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody describes an error with a machine readable code derived from the
+// status, a human readable message and, for invalid requests, the problem with
+// each field
+type ErrorBody struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when a request doesn't pass validation, it is
+// rendered with a detail for each invalid field
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Field + " " + field.Message
+	}
+	return strings.Join(messages, ", ")
+}
+
+// validationFailedCode is the code of requests rejected by validation
+const validationFailedCode = "validation_failed"
+
+// errorResponse writes an error response with the error envelope
+func errorResponse(c echo.Context, status int, message string) error {
+	return c.JSON(status, ErrorResponse{Error: ErrorBody{
+		Code:    errorCode(status),
+		Message: message,
+	}})
+}
+
+// errorCode turns a status into a code such as "not_found" or "internal_server_error"
+func errorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
+
+// HTTPErrorHandler renders the errors returned by handlers and middleware, such
+// as echo.NewHTTPError or validation errors, with the error envelope
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status := http.StatusInternalServerError
+	body := ErrorBody{Message: "Internal server error"}
+
+	var validationErr *ValidationError
+	var httpErr *echo.HTTPError
+	switch {
+	case errors.As(err, &validationErr):
+		status = http.StatusBadRequest
+		body = ErrorBody{Code: validationFailedCode, Message: "Invalid request", Details: validationErr.Fields}
+	case errors.As(err, &httpErr):
+		status = httpErr.Code
+		if text, ok := httpErr.Message.(string); ok {
+			body.Message = text
+		} else {
+			body.Message = http.StatusText(status)
+		}
+	default:
+		log.Printf("Unhandled error on %s %s: %v", c.Request().Method, c.Request().URL.Path, err)
+	}
+	if body.Code == "" {
+		body.Code = errorCode(status)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = c.JSON(status, ErrorResponse{Error: body})
+	}
+	if err != nil {
+		log.Printf("Error writing error response: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github/Rubncal04/youtube-premium/export"
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ExportHandler struct {
+	clientRepo  *repository.ClientRepository
+	paymentRepo *repository.PaymentRepository
+	ledgerRepo  *repository.LedgerRepository
+}
+
+func NewExportHandler(clientRepo *repository.ClientRepository, paymentRepo *repository.PaymentRepository, ledgerRepo *repository.LedgerRepository) *ExportHandler {
+	return &ExportHandler{
+		clientRepo:  clientRepo,
+		paymentRepo: paymentRepo,
+		ledgerRepo:  ledgerRepo,
+	}
+}
+
+// ExportClients handles exporting the clients of the authenticated user, honoring
+// the filters and sort of the client listing
+func (h *ExportHandler) ExportClients(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	filter, err := parseClientFilter(c)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+	query := parseListQuery(c, repository.ClientSortFields, "name", false)
+
+	return h.stream(c, "clients", func(w export.Writer) error {
+		if err := w.WriteRow("ID", "Name", "Cell phone", "Day to pay", "Status", "Last payment date", "Paid through", "Created at"); err != nil {
+			return err
+		}
+
+		return h.clientRepo.Each(userID, filter, query, func(client models.Client) error {
+			return w.WriteRow(client.ID.Hex(), client.Name, client.CellPhone, client.DayToPay, string(client.Status),
+				formatExportTime(client.LastPaymentDate), formatExportTime(client.PaidThrough), formatExportTime(client.CreatedAt))
+		})
+	})
+}
+
+// ExportPayments handles exporting the payments of the authenticated user, honoring
+// the filters and sort of the payment listing
+func (h *ExportHandler) ExportPayments(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	filter, err := parsePaymentFilter(c)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+	filter.UserID = userID
+	query := parseListQuery(c, repository.PaymentSortFields, "payment_date", true)
+
+	// Client names are looked up from the owner's clients, payments of clients in
+	// the trash are left out like in the listing
+	clients, err := h.clientRepo.GetAll(userID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get user's clients")
+	}
+	names := make(map[primitive.ObjectID]string, len(clients))
+	for _, client := range clients {
+		names[client.ID] = client.Name
+	}
+	filter.ExcludeClientIDs, err = h.clientRepo.GetDeletedIDs(userID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get user's clients")
+	}
+
+	return h.stream(c, "payments", func(w export.Writer) error {
+		if err := w.WriteRow("ID", "Client ID", "Client", "Amount", "Payment date", "Status", "Months", "Covered from", "Covered through", "Error"); err != nil {
+			return err
+		}
+
+		return h.paymentRepo.Each(filter, query, func(payment models.Payment) error {
+			return w.WriteRow(payment.ID.Hex(), payment.ClientID.Hex(), names[payment.ClientID], payment.Amount,
+				formatExportTime(payment.PaymentDate), string(payment.Status), payment.Months, payment.CoveredFrom,
+				payment.CoveredThrough, payment.Error)
+		})
+	})
+}
+
+// ExportLedger handles exporting the ledger of a client
+func (h *ExportHandler) ExportLedger(c echo.Context) error {
+	client := contextClient(c)
+
+	return h.stream(c, "ledger", func(w export.Writer) error {
+		if err := w.WriteRow("Date", "Type", "Kind", "Period", "Description", "Amount", "Balance"); err != nil {
+			return err
+		}
+
+		return h.ledgerRepo.EachEntry(client.ID, func(entry models.LedgerEntry) error {
+			return w.WriteRow(formatExportTime(entry.CreatedAt), string(entry.Type), string(entry.Kind), entry.Period,
+				entry.Description, entry.Amount, entry.Balance)
+		})
+	})
+}
+
+// stream validates the format parameter, sets the download headers and writes
+// the rows produced by write directly to the response
+func (h *ExportHandler) stream(c echo.Context, name string, write func(export.Writer) error) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if format != export.FormatCSV && format != export.FormatXLSX {
+		return errorResponse(c, http.StatusBadRequest, "Invalid format: must be csv or xlsx")
+	}
+
+	response := c.Response()
+	writer, err := export.NewWriter(format, response, name)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to create export")
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("2006-01-02"), format)
+	response.Header().Set(echo.HeaderContentType, export.ContentType(format))
+	response.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	response.WriteHeader(http.StatusOK)
+
+	// Once rows are sent the status can't change anymore, errors are only logged
+	err = write(writer)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("Error exporting %s: %v", name, err)
+	}
+
+	return nil
+}
+
+// formatExportTime formats a date for exports, leaving unset dates empty
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github/Rubncal04/youtube-premium/billing"
+	"github/Rubncal04/youtube-premium/importer"
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ImportHandler struct {
+	clientRepo  *repository.ClientRepository
+	paymentRepo *repository.PaymentRepository
+	billing     *billing.Service
+}
+
+// ImportResult is the outcome of an import. When any row has errors nothing is imported.
+type ImportResult struct {
+	DryRun   bool                `json:"dry_run"`
+	Rows     int                 `json:"rows"`
+	Imported int                 `json:"imported"`
+	Errors   []importer.RowError `json:"errors"`
+}
+
+func NewImportHandler(clientRepo *repository.ClientRepository, paymentRepo *repository.PaymentRepository, billingService *billing.Service) *ImportHandler {
+	return &ImportHandler{
+		clientRepo:  clientRepo,
+		paymentRepo: paymentRepo,
+		billing:     billingService,
+	}
+}
+
+// ImportClients handles importing the clients of the authenticated user from a
+// CSV file with the columns name, cell_phone and day_to_pay
+func (h *ImportHandler) ImportClients(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	dryRun, records, err := readImport(c, []string{"name", "cell_phone", "day_to_pay"})
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	existing, err := h.clientRepo.GetAll(userID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get user's clients")
+	}
+	phones := make(map[string]bool, len(existing)+len(records))
+	for _, client := range existing {
+		phones[client.CellPhone] = true
+	}
+
+	result := ImportResult{DryRun: dryRun, Rows: len(records), Errors: []importer.RowError{}}
+	clients := make([]models.Client, 0, len(records))
+	for _, record := range records {
+		rowErrors := []importer.RowError{}
+		fail := func(column, message string) {
+			rowErrors = append(rowErrors, importer.RowError{Line: record.Line, Column: column, Error: message})
+		}
+
+		name := record.Get("name")
+		if name == "" {
+			fail("name", "Name is required")
+		}
+
+		cellPhone := record.Get("cell_phone")
+		if cellPhone == "" {
+			fail("cell_phone", "Cell phone is required")
+		} else if phones[cellPhone] {
+			fail("cell_phone", "A client with this cell phone already exists")
+		}
+
+		dayToPay, err := strconv.Atoi(record.Get("day_to_pay"))
+		if err != nil || dayToPay < 1 || dayToPay > 31 {
+			fail("day_to_pay", "Day to pay must be a number between 1 and 31")
+		}
+
+		if len(rowErrors) > 0 {
+			result.Errors = append(result.Errors, rowErrors...)
+			continue
+		}
+
+		phones[cellPhone] = true
+		clients = append(clients, *models.NewClient(userID, name, cellPhone, dayToPay))
+	}
+
+	if len(result.Errors) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, result)
+	}
+	if dryRun {
+		return c.JSON(http.StatusOK, result)
+	}
+
+	if _, err := h.clientRepo.CreateMany(userID, clients); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to import clients")
+	}
+
+	result.Imported = len(clients)
+	return c.JSON(http.StatusCreated, result)
+}
+
+// ImportPayments handles importing historical payments of the authenticated
+// user's clients from a CSV file with the columns cell_phone, amount,
+// payment_date and optionally months. Payments are matched to clients by cell
+// phone, imported as completed and posted to the ledger in the same transaction.
+func (h *ImportHandler) ImportPayments(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	dryRun, records, err := readImport(c, []string{"cell_phone", "amount", "payment_date"}, "months")
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	clients, err := h.clientRepo.GetAll(userID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get user's clients")
+	}
+	byPhone := make(map[string]models.Client, len(clients))
+	for _, client := range clients {
+		byPhone[client.CellPhone] = client
+	}
+
+	result := ImportResult{DryRun: dryRun, Rows: len(records), Errors: []importer.RowError{}}
+	payments := make([]*models.Payment, 0, len(records))
+	now := time.Now()
+	for _, record := range records {
+		rowErrors := []importer.RowError{}
+		fail := func(column, message string) {
+			rowErrors = append(rowErrors, importer.RowError{Line: record.Line, Column: column, Error: message})
+		}
+
+		client, found := byPhone[record.Get("cell_phone")]
+		if !found {
+			fail("cell_phone", "No client with this cell phone")
+		}
+
+		amount, err := strconv.ParseFloat(record.Get("amount"), 64)
+		if err != nil || amount <= 0 {
+			fail("amount", "Amount must be a number greater than 0")
+		}
+
+		paymentDate, err := parseImportDate(record.Get("payment_date"))
+		if err != nil {
+			fail("payment_date", "Payment date must be YYYY-MM-DD or RFC 3339")
+		} else if paymentDate.After(now) {
+			fail("payment_date", "Payment date can't be in the future")
+		}
+
+		months := 1
+		if value := record.Get("months"); value != "" {
+			months, err = strconv.Atoi(value)
+			if err != nil || months < 1 || months > maxPrepaidMonths {
+				fail("months", fmt.Sprintf("Months must be between 1 and %d", maxPrepaidMonths))
+			}
+		}
+
+		if len(rowErrors) > 0 {
+			result.Errors = append(result.Errors, rowErrors...)
+			continue
+		}
+
+		// Historical payments cover the billing periods starting at the month they were made
+		payment := models.NewPayment(userID, client.ID, amount)
+		payment.PaymentDate = paymentDate
+		payment.SetCoverage(paymentDate, months)
+		payments = append(payments, payment)
+	}
+
+	if len(result.Errors) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, result)
+	}
+	if dryRun {
+		return c.JSON(http.StatusOK, result)
+	}
+
+	record := func(ctx mongo.SessionContext) error {
+		return h.billing.RecordImportedPayments(ctx, clients, payments)
+	}
+	if err := h.paymentRepo.ImportPayments(userID, payments, record); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to import payments")
+	}
+
+	result.Imported = len(payments)
+	return c.JSON(http.StatusCreated, result)
+}
+
+// readImport reads the dry_run parameter and the CSV sent either as the "file"
+// field of a multipart form or as a text/csv request body
+func readImport(c echo.Context, required []string, optional ...string) (bool, []importer.Record, error) {
+	dryRun := false
+	if value := c.QueryParam("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			return false, nil, errors.New("invalid dry_run: must be true or false")
+		}
+	}
+
+	var body io.Reader
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+		body = c.Request().Body
+	} else {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return false, nil, errors.New("a CSV file is required in the file field")
+		}
+		file, err := header.Open()
+		if err != nil {
+			return false, nil, errors.New("could not read the uploaded file")
+		}
+		defer file.Close()
+		body = file
+	}
+
+	records, err := importer.ReadCSV(body, required, optional...)
+	if err != nil {
+		return false, nil, err
+	}
+	return dryRun, records, nil
+}
+
+// parseImportDate parses a date of an imported row, either a day in the
+// server's local time or an RFC 3339 timestamp
+func parseImportDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+type LateFeeHandler struct {
+	policyRepo *repository.LateFeePolicyRepository
+	ledgerRepo *repository.LedgerRepository
+	clientRepo *repository.ClientRepository
+}
+
+type LateFeePolicyRequest struct {
+	Enabled      bool               `json:"enabled"`
+	Type         models.LateFeeType `json:"type"`
+	Value        float64            `json:"value"`
+	AfterDays    int                `json:"after_days"`
+	CapPerPeriod float64            `json:"cap_per_period"`
+}
+
+func NewLateFeeHandler(policyRepo *repository.LateFeePolicyRepository, ledgerRepo *repository.LedgerRepository, clientRepo *repository.ClientRepository) *LateFeeHandler {
+	return &LateFeeHandler{
+		policyRepo: policyRepo,
+		ledgerRepo: ledgerRepo,
+		clientRepo: clientRepo,
+	}
+}
+
+func (h *LateFeeHandler) GetPolicy(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	policy, err := h.policyRepo.GetByUserID(userID)
+	if err != nil {
+		return errorResponse(c, http.StatusNotFound, "Late fee policy not found")
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+// SavePolicy handles creating or replacing the late fee policy of the authenticated user
+func (h *LateFeeHandler) SavePolicy(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var request LateFeePolicyRequest
+	if err := bindRequest(c, &request); err != nil {
+		return err
+	}
+
+	policy := models.NewLateFeePolicy(userID, request.Enabled, request.Type, request.Value, request.AfterDays, request.CapPerPeriod)
+	if err := h.policyRepo.Save(policy); err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+func (h *LateFeeHandler) DeletePolicy(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.policyRepo.GetByUserID(userID); err != nil {
+		return errorResponse(c, http.StatusNotFound, "Late fee policy not found")
+	}
+
+	if err := h.policyRepo.Delete(userID); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Late fee policy deleted successfully"})
+}
+
+// ReverseLateFee handles giving a late fee back to a client
+func (h *LateFeeHandler) ReverseLateFee(c echo.Context) error {
+	client := contextClient(c)
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	fee, err := h.ledgerRepo.GetEntryByID(c.Param("entryId"))
+	if err != nil || fee.ClientID != client.ID {
+		return errorResponse(c, http.StatusNotFound, "Late fee not found")
+	}
+
+	reversal, err := h.ledgerRepo.ReverseLateFee(fee, userID)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.clientRepo.SyncStatusWithBalance(client, reversal.Balance); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to update client status")
+	}
+
+	return c.JSON(http.StatusCreated, reversal)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+type LedgerHandler struct {
+	ledgerRepo *repository.LedgerRepository
+	clientRepo *repository.ClientRepository
+}
+
+type LedgerEntryRequest struct {
+	Type        models.LedgerEntryType `json:"type"`
+	Kind        models.LedgerEntryKind `json:"kind"`
+	Amount      float64                `json:"amount"`
+	Description string                 `json:"description"`
+}
+
+// LedgerResponse is a page of a client's ledger with its current balance
+type LedgerResponse struct {
+	Balance float64 `json:"balance"`
+	repository.Page[models.LedgerEntry]
+}
+
+func NewLedgerHandler(ledgerRepo *repository.LedgerRepository, clientRepo *repository.ClientRepository) *LedgerHandler {
+	return &LedgerHandler{
+		ledgerRepo: ledgerRepo,
+		clientRepo: clientRepo,
+	}
+}
+
+// GetLedger handles getting the ledger of a client, newest entries first with
+// cursor pagination, and its current balance
+func (h *LedgerHandler) GetLedger(c echo.Context) error {
+	client := contextClient(c)
+
+	query := repository.ListQuery{Limit: parseLimit(c), Cursor: c.QueryParam("cursor")}
+	page, err := h.ledgerRepo.GetEntries(client.ID, query)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return errorResponse(c, http.StatusBadRequest, "Invalid cursor")
+		}
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get ledger")
+	}
+
+	balance, err := h.ledgerRepo.GetBalance(client.ID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get balance")
+	}
+
+	return c.JSON(http.StatusOK, LedgerResponse{Balance: balance, Page: page})
+}
+
+// CreateLedgerEntry handles manual refunds and adjustments on a client's balance
+func (h *LedgerHandler) CreateLedgerEntry(c echo.Context) error {
+	client := contextClient(c)
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var request LedgerEntryRequest
+	if err := bindRequest(c, &request); err != nil {
+		return err
+	}
+
+	// Charges and payments are only created by the system
+	if request.Kind != models.LedgerKindRefund && request.Kind != models.LedgerKindAdjustment {
+		return errorResponse(c, http.StatusBadRequest, "Only refund and adjustment entries can be created manually")
+	}
+
+	entry := models.NewLedgerEntry(client.ID, client.UserID, request.Type, request.Kind, request.Amount, request.Description)
+	entry.CreatedBy = userID
+
+	if err := h.ledgerRepo.AddEntry(entry); err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.clientRepo.SyncStatusWithBalance(client, entry.Balance); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to update client status")
+	}
+
+	return c.JSON(http.StatusCreated, entry)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github/Rubncal04/youtube-premium/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// parseLimit reads the limit query parameter, falling back to the default page
+// size when it is missing or invalid
+func parseLimit(c echo.Context) int {
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit < 1 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return limit
+}
+
+// parseListQuery reads the limit, cursor and sort query parameters of a cursor
+// paginated listing
+func parseListQuery(c echo.Context, sortFields map[string]string, defaultSort string, defaultDescending bool) repository.ListQuery {
+	field, descending := repository.ParseSort(c.QueryParam("sort"), sortFields, defaultSort, defaultDescending)
+
+	return repository.ListQuery{
+		SortField:  field,
+		Descending: descending,
+		Limit:      parseLimit(c),
+		Cursor:     c.QueryParam("cursor"),
+	}
+}
+
+// parseDateParam reads a date (YYYY-MM-DD) or timestamp (RFC 3339) query parameter.
+// When endOfDay is set, plain dates are moved to the last second of the day so
+// they can be used as an inclusive upper bound.
+func parseDateParam(c echo.Context, name string, endOfDay bool) (*time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected YYYY-MM-DD or RFC 3339", name)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	return &t, nil
+}
+
+// parseFloatParam reads an optional numeric query parameter
+func parseFloatParam(c echo.Context, name string) (*float64, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected a number", name)
+	}
+	return &f, nil
+}
+
+// parseIntParam reads an optional integer query parameter, returning 0 when missing
+func parseIntParam(c echo.Context, name string) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: expected an integer", name)
+	}
+	return i, nil
+}
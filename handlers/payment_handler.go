@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"errors"
+	"github/Rubncal04/youtube-premium/authz"
+	"github/Rubncal04/youtube-premium/billing"
 	"github/Rubncal04/youtube-premium/models"
 	"github/Rubncal04/youtube-premium/repository"
 	"net/http"
@@ -14,89 +17,106 @@ import (
 type PaymentHandler struct {
 	paymentRepo *repository.PaymentRepository
 	clientRepo  *repository.ClientRepository
+	authz       *authz.Service
+	billing     *billing.Service
 }
 
 type PaymentRequest struct {
 	Amount float64 `json:"amount" validate:"required,gt=0"`
+	Months int     `json:"months" validate:"omitempty,gte=1,lte=24"` // Billing periods covered, defaults to 1
 }
 
-func NewPaymentHandler(paymentRepo *repository.PaymentRepository, clientRepo *repository.ClientRepository) *PaymentHandler {
+// maxPrepaidMonths limits how far ahead a single payment can cover, it must match
+// the lte rule of PaymentRequest.Months
+const maxPrepaidMonths = 24
+
+func NewPaymentHandler(paymentRepo *repository.PaymentRepository, clientRepo *repository.ClientRepository, authzService *authz.Service, billingService *billing.Service) *PaymentHandler {
 	return &PaymentHandler{
 		paymentRepo: paymentRepo,
 		clientRepo:  clientRepo,
+		authz:       authzService,
+		billing:     billingService,
 	}
 }
 
-// GetAllPayments handles getting all payments for the authenticated user
+// GetAllPayments handles listing the payments of the authenticated user's clients,
+// or of the owner given in owner_id to a collaborator, with filters, sorting and
+// cursor pagination
 func (h *PaymentHandler) GetAllPayments(c echo.Context) error {
-	userID, ok := c.Get("user_id").(primitive.ObjectID)
-	if !ok {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	ownerID, err := resolveOwner(c, h.authz, authz.ActionRead)
+	if err != nil {
+		return err
+	}
+
+	filter, err := parsePaymentFilter(c)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
 	}
+	filter.UserID = ownerID
 
-	// Get all clients for the user
-	clients, err := h.clientRepo.GetAll(userID)
+	// Payments of clients in the trash are not listed
+	filter.ExcludeClientIDs, err = h.clientRepo.GetDeletedIDs(ownerID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get user's clients"})
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get user's clients")
 	}
+	query := parseListQuery(c, repository.PaymentSortFields, "payment_date", true)
 
-	// Get all payments for these clients
-	var allPayments []models.Payment
-	for _, client := range clients {
-		payments, err := h.paymentRepo.GetPaymentsByClientID(client.ID)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get payments"})
+	page, err := h.paymentRepo.List(filter, query)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return errorResponse(c, http.StatusBadRequest, "Invalid cursor")
 		}
-		allPayments = append(allPayments, payments...)
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get payments")
 	}
 
-	return c.JSON(http.StatusOK, allPayments)
+	return c.JSON(http.StatusOK, page)
 }
 
-// GetOnePayment handles getting a single payment by ID
-func (h *PaymentHandler) GetOnePayment(c echo.Context) error {
+// parsePaymentFilter reads the filters of the payment listing from the query string
+func parsePaymentFilter(c echo.Context) (repository.PaymentFilter, error) {
+	filter := repository.PaymentFilter{
+		Status: models.PaymentStatus(c.QueryParam("status")),
+	}
 
-	clientID, err := primitive.ObjectIDFromHex(c.Param("clientId"))
-	if err != nil {
-		fmt.Printf("GetOnePayment: Error on convert clientId: %v\n", err)
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid client ID"})
+	var err error
+	if filter.DateFrom, err = parseDateParam(c, "date_from", false); err != nil {
+		return filter, err
+	}
+	if filter.DateTo, err = parseDateParam(c, "date_to", true); err != nil {
+		return filter, err
+	}
+	if filter.AmountMin, err = parseFloatParam(c, "amount_min"); err != nil {
+		return filter, err
 	}
+	if filter.AmountMax, err = parseFloatParam(c, "amount_max"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// GetOnePayment handles getting a single payment by ID
+func (h *PaymentHandler) GetOnePayment(c echo.Context) error {
 
 	paymentID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		fmt.Printf("GetOnePayment: Error on convert paymentId: %v\n", err)
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid payment ID"})
+		return errorResponse(c, http.StatusBadRequest, "Invalid payment ID")
 	}
 
-	// Verify that the client belongs to the authenticated user
-	client, err := h.clientRepo.GetByID(clientID.Hex())
-	if err != nil {
-		fmt.Printf("GetOnePayment: Error on get client: %v\n", err)
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Client not found"})
-	}
-
-	userID, ok := c.Get("user_id").(primitive.ObjectID)
-	if !ok {
-		fmt.Println("GetOnePayment: Error on get user_id from context")
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-	}
-
-	if client.UserID != userID {
-		fmt.Printf("GetOnePayment: Error on verify user_id: %v with client_id: %v\n", userID, client.UserID)
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-	}
+	client := contextClient(c)
 
 	// Get the payment
 	payment, err := h.paymentRepo.GetByID(paymentID)
 	if err != nil {
 		fmt.Printf("GetOnePayment: Error on get payment: %v\n", err)
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Payment not found"})
+		return errorResponse(c, http.StatusNotFound, "Payment not found")
 	}
 
 	// Verify that the payment belongs to the client
-	if payment.ClientID != clientID {
-		fmt.Printf("GetOnePayment: Error on verify payment_id: %v with client_id: %v\n", paymentID, clientID)
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Payment not found for this client"})
+	if payment.ClientID != client.ID {
+		fmt.Printf("GetOnePayment: Error on verify payment_id: %v with client_id: %v\n", paymentID, client.ID)
+		return errorResponse(c, http.StatusNotFound, "Payment not found for this client")
 	}
 
 	return c.JSON(http.StatusOK, payment)
@@ -104,59 +124,57 @@ func (h *PaymentHandler) GetOnePayment(c echo.Context) error {
 
 // GetPaymentsByClient handles getting all payments for a specific client
 func (h *PaymentHandler) GetPaymentsByClient(c echo.Context) error {
-	clientID, err := primitive.ObjectIDFromHex(c.Param("clientId"))
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid client ID"})
-	}
+	client := contextClient(c)
 
-	// Verify that the client belongs to the authenticated user
-	client, err := h.clientRepo.GetByID(clientID.Hex())
+	payments, err := h.paymentRepo.GetPaymentsByClientID(client.ID)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Client not found"})
-	}
-
-	userID, ok := c.Get("user_id").(primitive.ObjectID)
-	if !ok || client.UserID != userID {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-	}
-
-	payments, err := h.paymentRepo.GetPaymentsByClientID(clientID)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get payments"})
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get payments")
 	}
 
 	return c.JSON(http.StatusOK, payments)
 }
 
-// CreatePayment handles creating a new payment
+// CreatePayment handles creating a new payment. A payment covering several
+// months must pay for all of them; a single month paid in part is credited to
+// the client's balance without covering the month.
 func (h *PaymentHandler) CreatePayment(c echo.Context) error {
-	clientID, err := primitive.ObjectIDFromHex(c.Param("clientId"))
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid client ID"})
-	}
+	client := contextClient(c)
 
-	// Verify that the client belongs to the authenticated user
-	client, err := h.clientRepo.GetByID(clientID.Hex())
-	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Client not found"})
+	var paymentRequest PaymentRequest
+	if err := bindRequest(c, &paymentRequest); err != nil {
+		return err
 	}
 
-	userID, ok := c.Get("user_id").(primitive.ObjectID)
-	if !ok || client.UserID != userID {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	// Amount and months are validated by the request's tags
+	months := paymentRequest.Months
+	if months == 0 {
+		months = 1
 	}
 
-	var paymentRequest PaymentRequest
-	if err := c.Bind(&paymentRequest); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
-	}
+	// Create new payment in processing state, covering the next unpaid billing periods
+	payment := models.NewPayment(client.UserID, client.ID, paymentRequest.Amount)
+	payment.SetCoverage(client.NextUnpaidPeriodStart(payment.PaymentDate), months)
 
-	// Create new payment in processing state
-	payment := models.NewPayment(clientID, paymentRequest.Amount)
+	// Without a price configuration the periods can't be priced, single months
+	// are taken at face value as before prices were checked
+	due, err := h.billing.AmountToSettle(*client, payment.CoveredPeriods())
+	switch {
+	case errors.Is(err, billing.ErrNoPrice):
+		if months > 1 {
+			return errorResponse(c, http.StatusBadRequest, "Set a price configuration before taking payments for several months")
+		}
+	case err != nil:
+		return errorResponse(c, http.StatusInternalServerError, "Failed to compute the amount due")
+	case payment.Amount < due-0.005:
+		if months > 1 {
+			return errorResponse(c, http.StatusBadRequest, fmt.Sprintf("The amount doesn't cover %d months, %.2f is due", months, due))
+		}
+		payment.MarkPartial()
+	}
 
 	// Save payment in processing state
 	if err := h.paymentRepo.CreatePayment(payment); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create payment"})
+		return errorResponse(c, http.StatusInternalServerError, "Failed to create payment")
 	}
 
 	// Try to process the payment
@@ -164,15 +182,28 @@ func (h *PaymentHandler) CreatePayment(c echo.Context) error {
 	// For now, we'll simulate a successful payment
 	if err := h.processPayment(payment); err != nil {
 		// If payment processing fails, update status to rejected
-		if updateErr := h.paymentRepo.RejectPayment(payment.ID, err.Error()); updateErr != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update payment status"})
+		if updateErr := h.paymentRepo.RejectPayment(payment, err.Error()); updateErr != nil {
+			return errorResponse(c, http.StatusInternalServerError, "Failed to update payment status")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Payment processing failed"})
+		return errorResponse(c, http.StatusInternalServerError, "Payment processing failed")
 	}
 
 	// Update client's last payment date
-	if err := h.clientRepo.UpdateLastPaymentDate(clientID, primitive.NewDateTimeFromTime(payment.PaymentDate)); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update client's last payment date"})
+	if err := h.clientRepo.UpdateLastPaymentDate(client.ID, primitive.NewDateTimeFromTime(payment.PaymentDate)); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to update client's last payment date")
+	}
+
+	// Mark the covered billing periods as paid
+	if !payment.Partial {
+		if err := h.clientRepo.UpdatePaidThrough(client.ID, payment.PaidThrough(payment.PaymentDate.Location())); err != nil {
+			return errorResponse(c, http.StatusInternalServerError, "Failed to update client's paid through date")
+		}
+	}
+
+	// Credit the payment and charge the periods it covers, any excess stays as
+	// credit for future periods and any shortfall as debt
+	if err := h.billing.RecordPayment(client, payment); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to record payment in ledger")
 	}
 
 	return c.JSON(http.StatusCreated, payment)
@@ -186,5 +217,5 @@ func (h *PaymentHandler) processPayment(payment *models.Payment) error {
 	// and handle the response
 
 	// For demonstration, we'll just complete the payment
-	return h.paymentRepo.CompletePayment(payment.ID)
+	return h.paymentRepo.CompletePayment(payment)
 }
@@ -6,7 +6,6 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type PriceConfigurationHandler struct {
@@ -18,77 +17,79 @@ func NewPriceConfigurationHandler(repo *repository.PriceConfigurationRepository)
 }
 
 type PriceConfigRequest struct {
-	Amount float64 `json:"amount"`
+	Amount float64 `json:"amount" validate:"required,gt=0"`
 }
 
 func (h *PriceConfigurationHandler) CreatePriceConfig(c echo.Context) error {
-	userID := c.Get("user_id").(primitive.ObjectID)
-
-	var request PriceConfigRequest
-	if err := c.Bind(&request); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
 	}
 
-	if request.Amount <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Amount must be greater than 0"})
+	var request PriceConfigRequest
+	if err := bindRequest(c, &request); err != nil {
+		return err
 	}
 
 	config := models.NewPriceConfiguration(userID, request.Amount)
 
 	if err := h.priceConfigRepo.Create(config); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return errorResponse(c, http.StatusBadRequest, err.Error())
 	}
 
 	return c.JSON(http.StatusCreated, config)
 }
 
 func (h *PriceConfigurationHandler) GetPriceConfig(c echo.Context) error {
-	userID := c.Get("user_id").(primitive.ObjectID)
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
 
 	config, err := h.priceConfigRepo.GetByUserID(userID)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Price configuration not found"})
+		return errorResponse(c, http.StatusNotFound, "Price configuration not found")
 	}
 
 	return c.JSON(http.StatusOK, config)
 }
 
 func (h *PriceConfigurationHandler) UpdatePriceConfig(c echo.Context) error {
-	userID := c.Get("user_id").(primitive.ObjectID)
-
-	var request PriceConfigRequest
-	if err := c.Bind(&request); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
 	}
 
-	if request.Amount <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Amount must be greater than 0"})
+	var request PriceConfigRequest
+	if err := bindRequest(c, &request); err != nil {
+		return err
 	}
 
 	// Verify if the configuration exists
-	_, err := h.priceConfigRepo.GetByUserID(userID)
-	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Price configuration not found"})
+	if _, err := h.priceConfigRepo.GetByUserID(userID); err != nil {
+		return errorResponse(c, http.StatusNotFound, "Price configuration not found")
 	}
 
 	if err := h.priceConfigRepo.Update(userID, request.Amount); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return errorResponse(c, http.StatusInternalServerError, err.Error())
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Price configuration updated successfully"})
 }
 
 func (h *PriceConfigurationHandler) DeletePriceConfig(c echo.Context) error {
-	userID := c.Get("user_id").(primitive.ObjectID)
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
 
 	// Verify if the configuration exists
-	_, err := h.priceConfigRepo.GetByUserID(userID)
-	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Price configuration not found"})
+	if _, err := h.priceConfigRepo.GetByUserID(userID); err != nil {
+		return errorResponse(c, http.StatusNotFound, "Price configuration not found")
 	}
 
 	if err := h.priceConfigRepo.Delete(userID); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return errorResponse(c, http.StatusInternalServerError, err.Error())
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Price configuration deleted successfully"})
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github/Rubncal04/youtube-premium/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+type StatsHandler struct {
+	statsRepo       *repository.StatsRepository
+	priceConfigRepo *repository.PriceConfigurationRepository
+}
+
+func NewStatsHandler(statsRepo *repository.StatsRepository, priceConfigRepo *repository.PriceConfigurationRepository) *StatsHandler {
+	return &StatsHandler{
+		statsRepo:       statsRepo,
+		priceConfigRepo: priceConfigRepo,
+	}
+}
+
+// GetStats handles getting the dashboard statistics of the authenticated user.
+// The range defaults to the current month.
+func (h *StatsHandler) GetStats(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	// The end of today keeps the cache key the same for the whole day
+	to := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location()).Add(-time.Second)
+
+	if value, err := parseDateParam(c, "from", false); err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	} else if value != nil {
+		from = *value
+	}
+	if value, err := parseDateParam(c, "to", true); err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	} else if value != nil {
+		to = *value
+	}
+	if to.Before(from) {
+		return errorResponse(c, http.StatusBadRequest, "to must be after from")
+	}
+
+	// Without a price configuration nothing is expected
+	var price float64
+	if config, err := h.priceConfigRepo.GetByUserID(userID); err == nil {
+		price = config.Amount
+	}
+
+	stats, err := h.statsRepo.GetStats(userID, from, to, price)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to get stats")
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github/Rubncal04/youtube-premium/auth"
+	"github/Rubncal04/youtube-premium/middleware"
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/ratelimit"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// totpIssuer names the account in authenticator apps
+const totpIssuer = "YouTube Premium"
+
+// recoveryCodeCount is how many recovery codes a user gets
+const recoveryCodeCount = 10
+
+// TwoFactorHandler handles the optional two-factor authentication with the
+// codes of an authenticator app (TOTP), and the second step of the login of
+// the users that enabled it
+type TwoFactorHandler struct {
+	userRepo         *repository.UserRepository
+	refreshTokenRepo *repository.RefreshTokenRepository
+	keys             *auth.KeySet
+	denylist         *auth.Denylist
+	guard            *ratelimit.LoginGuard
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"` // TOTP or recovery code
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"` // TOTP or recovery code
+}
+
+// TwoFactorEnrollmentResponse carries the secret to add to an authenticator
+// app, by hand or as the QR code of the otpauth URI
+type TwoFactorEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesResponse is returned once, when the codes are generated: only
+// their hashes are stored
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func NewTwoFactorHandler(userRepo *repository.UserRepository, refreshTokenRepo *repository.RefreshTokenRepository, keys *auth.KeySet, denylist *auth.Denylist, guard *ratelimit.LoginGuard) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		keys:             keys,
+		denylist:         denylist,
+		guard:            guard,
+	}
+}
+
+// Enroll handles starting the enrollment of the authenticated user. The secret
+// takes effect once Confirm receives a code generated with it.
+func (h *TwoFactorHandler) Enroll(c echo.Context) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+	if user.TwoFactorEnabled {
+		return errorResponse(c, http.StatusConflict, "Two-factor authentication is already enabled")
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to start enrollment")
+	}
+	if err := h.userRepo.SetPendingTOTPSecret(user.ID, secret); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to start enrollment")
+	}
+
+	return c.JSON(http.StatusOK, TwoFactorEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// Confirm handles enabling two-factor authentication with a code of the secret
+// returned by Enroll. The response carries the recovery codes.
+func (h *TwoFactorHandler) Confirm(c echo.Context) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req TwoFactorCodeRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	if user.TwoFactorEnabled {
+		return errorResponse(c, http.StatusConflict, "Two-factor authentication is already enabled")
+	}
+	if user.TOTPPendingSecret == "" {
+		return errorResponse(c, http.StatusConflict, "Start the enrollment first")
+	}
+	step, ok := auth.ValidateTOTP(user.TOTPPendingSecret, req.Code, time.Now())
+	if !ok {
+		return errorResponse(c, http.StatusBadRequest, "Invalid code")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+	}
+	if err := h.userRepo.EnableTwoFactor(user.ID, user.TOTPPendingSecret, step, hashes); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+	}
+
+	return c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes handles replacing the recovery codes of the
+// authenticated user, the previous ones stop working
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req TwoFactorCodeRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	if !user.TwoFactorEnabled {
+		return errorResponse(c, http.StatusConflict, "Two-factor authentication is not enabled")
+	}
+	step, ok := auth.ValidateTOTP(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		return errorResponse(c, http.StatusBadRequest, "Invalid code")
+	}
+	if used, err := h.userRepo.UseTOTPStep(user.ID, step); err != nil || !used {
+		return errorResponse(c, http.StatusBadRequest, "Invalid code")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to generate recovery codes")
+	}
+	if err := h.userRepo.SetRecoveryCodes(user.ID, hashes); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to generate recovery codes")
+	}
+
+	return c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable handles turning off two-factor authentication, which takes the
+// password and a code of the authenticated user
+func (h *TwoFactorHandler) Disable(c echo.Context) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req DisableTwoFactorRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	if !user.TwoFactorEnabled {
+		return errorResponse(c, http.StatusConflict, "Two-factor authentication is not enabled")
+	}
+	if err := user.CheckPassword(req.Password); err != nil {
+		return errorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+	}
+	if !h.verifyCode(user, req.Code) {
+		return errorResponse(c, http.StatusBadRequest, "Invalid code")
+	}
+
+	if err := h.userRepo.DisableTwoFactor(user.ID); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "two-factor authentication disabled",
+	})
+}
+
+// VerifyLogin handles the second step of the login: it exchanges the challenge
+// returned by Login and a TOTP or recovery code for a token pair. Failed codes
+// count towards the lockout of the account, and a challenge is denied once a
+// code has been accepted for it.
+func (h *TwoFactorHandler) VerifyLogin(c echo.Context) error {
+	var req TwoFactorLoginRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	claims, err := auth.ValidateToken(req.ChallengeToken, h.keys, auth.TokenTypeTwoFactorChallenge)
+	if err != nil || h.denylist.IsRevoked(ctx, claims) {
+		return errorResponse(c, http.StatusUnauthorized, "Invalid or expired challenge, log in again")
+	}
+	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return errorResponse(c, http.StatusUnauthorized, "Invalid or expired challenge, log in again")
+	}
+
+	account := "2fa:" + claims.UserID
+	if wait := h.guard.Check(ctx, account, c.RealIP()); wait > 0 {
+		middleware.SetRetryAfter(c, wait)
+		return errorResponse(c, http.StatusTooManyRequests, "Too many attempts, try again later")
+	}
+
+	user, err := h.userRepo.GetUserByID(userID)
+	if err != nil || !user.TwoFactorEnabled {
+		return errorResponse(c, http.StatusUnauthorized, "Invalid or expired challenge, log in again")
+	}
+	if user.Disabled {
+		return errorResponse(c, http.StatusForbidden, "Account is disabled")
+	}
+
+	if !h.verifyCode(user, req.Code) {
+		h.guard.Fail(ctx, account, c.RealIP())
+		return errorResponse(c, http.StatusUnauthorized, "Invalid code")
+	}
+	h.guard.Succeed(ctx, account, c.RealIP())
+
+	if err := h.denylist.RevokeToken(ctx, claims); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to verify the challenge")
+	}
+
+	tokenPair, err := issueTokens(h.refreshTokenRepo, user, "", h.keys)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to generate tokens")
+	}
+
+	return c.JSON(http.StatusOK, newAuthResponse(user, tokenPair))
+}
+
+// verifyCode checks a TOTP code of the user, or else one of their recovery
+// codes. Each code works once.
+func (h *TwoFactorHandler) verifyCode(user *models.User, code string) bool {
+	if step, ok := auth.ValidateTOTP(user.TOTPSecret, code, time.Now()); ok {
+		used, err := h.userRepo.UseTOTPStep(user.ID, step)
+		if err != nil {
+			log.Printf("Error recording TOTP code of user %s: %v", user.ID.Hex(), err)
+		}
+		return used
+	}
+
+	used, err := h.userRepo.UseRecoveryCode(user.ID, hashToken(auth.NormalizeRecoveryCode(code)))
+	if err != nil {
+		log.Printf("Error using recovery code of user %s: %v", user.ID.Hex(), err)
+	}
+	return used
+}
+
+func (h *TwoFactorHandler) currentUser(c echo.Context) (*models.User, error) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return nil, err
+	}
+	user, err := h.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+	return user, nil
+}
+
+// newRecoveryCodes returns new recovery codes along with the hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashToken(auth.NormalizeRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
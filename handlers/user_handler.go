@@ -1,11 +1,20 @@
 package handlers
 
 import (
+	"net/http"
+
+	"github/Rubncal04/youtube-premium/auth"
+	"github/Rubncal04/youtube-premium/models"
 	"github/Rubncal04/youtube-premium/repository"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type UserHandler struct {
-	Repo *repository.UserRepository
+	Repo      *repository.UserRepository
+	tokenRepo *repository.RefreshTokenRepository
+	denylist  *auth.Denylist
 }
 
 type UserRequest struct {
@@ -15,6 +24,99 @@ type UserRequest struct {
 	DateToPay *string `json:"date_to_pay"`
 }
 
-func NewUserHandler(repo *repository.UserRepository) *UserHandler {
-	return &UserHandler{Repo: repo}
+type RoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin owner assistant"`
+}
+
+func NewUserHandler(repo *repository.UserRepository, tokenRepo *repository.RefreshTokenRepository, denylist *auth.Denylist) *UserHandler {
+	return &UserHandler{Repo: repo, tokenRepo: tokenRepo, denylist: denylist}
+}
+
+// GetUsers lists every user, for admins
+func (h *UserHandler) GetUsers(c echo.Context) error {
+	users, err := h.Repo.GetAllUsers()
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Error fetching users")
+	}
+
+	return c.JSON(http.StatusOK, users)
+}
+
+// UpdateRole changes the role of a user. Their access tokens are revoked so the
+// new role applies on their next refresh.
+func (h *UserHandler) UpdateRole(c echo.Context) error {
+	user, err := h.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req RoleRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.Repo.SetRole(user.ID, req.Role); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Error updating role")
+	}
+	if err := h.denylist.RevokeUser(c.Request().Context(), user.ID.Hex()); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Error revoking tokens")
+	}
+
+	user.Role = req.Role
+	return c.JSON(http.StatusOK, user)
+}
+
+// DisableUser disables an account and logs out every session of it
+func (h *UserHandler) DisableUser(c echo.Context) error {
+	user, err := h.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Repo.SetDisabled(user.ID, true); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Error disabling user")
+	}
+	if err := revokeSessions(c, user.ID, h.tokenRepo, h.denylist); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Error revoking tokens")
+	}
+
+	user.Disabled = true
+	return c.JSON(http.StatusOK, user)
+}
+
+// EnableUser enables a disabled account, its user has to log in again
+func (h *UserHandler) EnableUser(c echo.Context) error {
+	user, err := h.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Repo.SetDisabled(user.ID, false); err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Error enabling user")
+	}
+
+	user.Disabled = false
+	return c.JSON(http.StatusOK, user)
+}
+
+// targetUser loads the user of the :id parameter. Admins can't change their own
+// account, so they can't lock themselves out.
+func (h *UserHandler) targetUser(c echo.Context) (*models.User, error) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+	currentID, err := currentUserID(c)
+	if err != nil {
+		return nil, err
+	}
+	if userID == currentID {
+		return nil, echo.NewHTTPError(http.StatusConflict, "You can't change your own account")
+	}
+
+	user, err := h.Repo.GetUserByID(userID)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+	return user, nil
 }
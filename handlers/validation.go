@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// RequestValidator validates request structs using their validate tags, it is
+// registered as the Echo validator
+type RequestValidator struct {
+	validate *validator.Validate
+}
+
+func NewRequestValidator() *RequestValidator {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON name, as clients send them
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	return &RequestValidator{validate: validate}
+}
+
+// Validate checks a request struct, returning a *ValidationError listing every invalid field
+func (v *RequestValidator) Validate(i any) error {
+	err := v.validate.Struct(i)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	fields := make([]FieldError, len(fieldErrs))
+	for i, fieldErr := range fieldErrs {
+		fields[i] = FieldError{Field: fieldErr.Field(), Message: validationMessage(fieldErr)}
+	}
+	return &ValidationError{Fields: fields}
+}
+
+// validationMessage describes a failed validation rule
+func validationMessage(err validator.FieldError) string {
+	switch err.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", err.Param())
+	case "gte":
+		return fmt.Sprintf("must be greater than or equal to %s", err.Param())
+	case "lt":
+		return fmt.Sprintf("must be less than %s", err.Param())
+	case "lte":
+		return fmt.Sprintf("must be less than or equal to %s", err.Param())
+	case "min":
+		if err.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", err.Param())
+		}
+		return fmt.Sprintf("must be at least %s", err.Param())
+	case "max":
+		if err.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", err.Param())
+		}
+		return fmt.Sprintf("must be at most %s", err.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", err.Param())
+	default:
+		return fmt.Sprintf("is invalid (%s)", err.Tag())
+	}
+}
+
+// bindRequest binds the request body into req and validates it. The returned
+// error is rendered by HTTPErrorHandler, so handlers can return it as is.
+func bindRequest(c echo.Context, req any) error {
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	return c.Validate(req)
+}
@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MaxRows limits how many rows a single import can contain
+const MaxRows = 5000
+
+// Record is a data row of a CSV file, with its values keyed by column name
+type Record struct {
+	Line   int // Line of the row in the file, the header being line 1
+	values map[string]string
+}
+
+// Get returns the trimmed value of a column, empty if the column is missing
+func (r Record) Get(column string) string {
+	return r.values[column]
+}
+
+// RowError describes why a row of an import can't be imported
+type RowError struct {
+	Line   int    `json:"line"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+// ReadCSV reads a CSV file whose first line names its columns. Column names are
+// matched case-insensitively and every required column must be present; columns
+// not listed in required or optional are ignored. Empty lines are skipped.
+func ReadCSV(r io.Reader, required []string, optional ...string) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("file is empty")
+		}
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	known := make(map[string]bool, len(required)+len(optional))
+	for _, column := range required {
+		known[column] = true
+	}
+	for _, column := range optional {
+		known[column] = true
+	}
+
+	columns := make([]string, len(header))
+	present := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if known[name] {
+			columns[i] = name
+			present[name] = true
+		}
+	}
+	for _, column := range required {
+		if !present[column] {
+			return nil, fmt.Errorf("missing column: %s", column)
+		}
+	}
+
+	var records []Record
+	for {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		record := Record{Line: line, values: make(map[string]string, len(columns))}
+		for i, value := range values {
+			if i < len(columns) && columns[i] != "" {
+				record.values[columns[i]] = strings.TrimSpace(value)
+			}
+		}
+
+		records = append(records, record)
+		if len(records) > MaxRows {
+			return nil, fmt.Errorf("too many rows: the limit is %d", MaxRows)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("file has no rows")
+	}
+	return records, nil
+}
@@ -5,61 +5,142 @@ import (
 	"strings"
 
 	"github/Rubncal04/youtube-premium/auth"
+	"github/Rubncal04/youtube-premium/models"
 
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func AuthMiddleware(secretKey string) echo.MiddlewareFunc {
+// APIKeyContextKey is the context key of the API key that authenticated a
+// request, unset for requests authenticated with a bearer token
+const APIKeyContextKey = "api_key"
+
+// APIKeyHeader carries the API keys of scripts and integrations
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator resolves an API key to the key and its user
+type APIKeyAuthenticator func(key string) (*models.APIKey, *models.User, error)
+
+// AuthMiddleware validates the bearer token, rejects it when it is in the
+// denylist and sets the user_id, role and claims of the request. Requests
+// without an Authorization header may send an API key in the X-API-Key header
+// instead. Errors are returned as echo.HTTPError so the server's error handler
+// renders them.
+func AuthMiddleware(keys *auth.KeySet, denylist *auth.Denylist, apiKeys APIKeyAuthenticator) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			// Skip authentication for public routes
-			if c.Request().URL.Path == "/register" || c.Request().URL.Path == "/login" || c.Request().URL.Path == "/refresh" || c.Request().URL.Path == "/" {
+			if c.Request().URL.Path == "/register" || c.Request().URL.Path == "/login" || c.Request().URL.Path == "/refresh" || c.Request().URL.Path == "/logout" || c.Request().URL.Path == "/" {
 				return next(c)
 			}
 
 			authHeader := c.Request().Header.Get("Authorization")
+			if authHeader == "" && apiKeys != nil {
+				if key := c.Request().Header.Get(APIKeyHeader); key != "" {
+					return authenticateAPIKey(c, next, apiKeys, key)
+				}
+			}
 			if authHeader == "" {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "missing authorization header",
-				})
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing authorization header")
 			}
 
 			// Check if the header starts with "Bearer "
 			if !strings.HasPrefix(authHeader, "Bearer ") {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "invalid authorization header format",
-				})
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authorization header format")
 			}
 
 			// Extract the token
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
 			// Validate the token
-			claims, err := auth.ValidateToken(tokenString, secretKey)
+			claims, err := auth.ValidateToken(tokenString, keys, auth.TokenTypeAccess)
 			if err != nil {
 				if err == auth.ErrExpiredToken {
-					return c.JSON(http.StatusUnauthorized, map[string]string{
-						"error": "token has expired",
-					})
+					return echo.NewHTTPError(http.StatusUnauthorized, "Token has expired")
 				}
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "invalid token",
-				})
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token")
+			}
+			if denylist != nil && denylist.IsRevoked(c.Request().Context(), claims) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Token has been revoked")
 			}
 
 			// Convert userID string to ObjectID
 			userID, err := primitive.ObjectIDFromHex(claims.UserID)
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "invalid user ID in token",
-				})
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid user ID in token")
+			}
+
+			// Tokens issued before roles were added carry none, they belonged to owners
+			role := claims.Role
+			if role == "" {
+				role = models.RoleOwner
 			}
 
 			// Set the user ID in the context
 			c.Set("user_id", userID)
+			c.Set("role", role)
+			c.Set("claims", claims)
+
+			return next(c)
+		}
+	}
+}
+
+// authenticateAPIKey sets the user_id and role of the owner of an API key, the
+// same values a bearer token of theirs sets
+func authenticateAPIKey(c echo.Context, next echo.HandlerFunc, apiKeys APIKeyAuthenticator, key string) error {
+	apiKey, user, err := apiKeys(key)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+	}
+	if user.Disabled {
+		return echo.NewHTTPError(http.StatusForbidden, "Account is disabled")
+	}
+
+	role := user.Role
+	if role == "" {
+		role = models.RoleOwner
+	}
+
+	c.Set("user_id", user.ID)
+	c.Set("role", role)
+	c.Set(APIKeyContextKey, apiKey)
+	// Claims as an access token of the user would carry them, for RequirePermission
+	c.Set("claims", &auth.Claims{UserID: user.ID.Hex(), Role: role, Unverified: !user.EmailVerified})
 
+	return next(c)
+}
+
+// RequirePermission rejects requests whose role doesn't grant a permission.
+// Users who haven't verified their email only get the read permission, and
+// API keys only the permissions of their scopes. It runs after AuthMiddleware,
+// which sets the role and claims.
+func RequirePermission(permission auth.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, _ := c.Get("role").(string)
+			if !auth.HasPermission(role, permission) {
+				return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
+			}
+			if claims, ok := c.Get("claims").(*auth.Claims); ok && claims.Unverified && permission != auth.PermissionRead {
+				return echo.NewHTTPError(http.StatusForbidden, "Verify your email address to make changes")
+			}
+			if apiKey, ok := c.Get(APIKeyContextKey).(*models.APIKey); ok && !apiKey.HasScope(string(permission)) {
+				return echo.NewHTTPError(http.StatusForbidden, "API key is missing the "+string(permission)+" scope")
+			}
 			return next(c)
 		}
 	}
 }
+
+// RequireSession rejects requests authenticated with an API key, for the
+// routes that manage the account itself: its password, sessions, two-factor
+// authentication and API keys take a login
+func RequireSession(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if _, ok := c.Get(APIKeyContextKey).(*models.APIKey); ok {
+			return echo.NewHTTPError(http.StatusForbidden, "API keys can't be used on this route, log in instead")
+		}
+		return next(c)
+	}
+}
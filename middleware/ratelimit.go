@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github/Rubncal04/youtube-premium/cache"
+	"github/Rubncal04/youtube-premium/ratelimit"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RateLimit rejects the requests over a limit with 429 and a Retry-After
+// header. Requests are counted per name and the key returned by key, such as
+// the client IP. Every response carries the X-RateLimit-Limit and
+// X-RateLimit-Remaining headers.
+func RateLimit(limiter *ratelimit.Limiter, name string, limit ratelimit.Limit, key func(echo.Context) string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if limit.Requests <= 0 {
+				return next(c)
+			}
+
+			result := limiter.Allow(c.Request().Context(), cache.GenerateKey(name, key(c)), limit)
+			header := c.Response().Header()
+			header.Set("X-RateLimit-Limit", strconv.Itoa(limit.Requests))
+			header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			if !result.Allowed {
+				SetRetryAfter(c, result.RetryAfter)
+				return echo.NewHTTPError(http.StatusTooManyRequests, "Too many requests, try again later")
+			}
+			return next(c)
+		}
+	}
+}
+
+// RateLimitByIP counts requests per client IP
+func RateLimitByIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// RateLimitByUser counts requests per authenticated user, and per client IP
+// before AuthMiddleware sets the user
+func RateLimitByUser(c echo.Context) string {
+	if userID, ok := c.Get("user_id").(primitive.ObjectID); ok {
+		return "user:" + userID.Hex()
+	}
+	return RateLimitByIP(c)
+}
+
+// SetRetryAfter sets the Retry-After header in whole seconds, rounded up
+func SetRetryAfter(c echo.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+}
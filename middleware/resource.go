@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github/Rubncal04/youtube-premium/authz"
+	"github/Rubncal04/youtube-premium/models"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ClientContextKey is the context key of the client loaded by LoadClient
+const ClientContextKey = "client"
+
+// ClientLoader finds a client by its hex ID
+type ClientLoader func(id string) (*models.Client, error)
+
+// LoadClient resolves the client of a route parameter, checks that the
+// authenticated user may perform an action on it and stores it in the context.
+// Clients of owners the user has no access to are reported as not found, so
+// their IDs can't be told apart from missing ones. It runs after AuthMiddleware.
+func LoadClient(param string, load ClientLoader, service *authz.Service, action authz.Action) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, ok := c.Get("user_id").(primitive.ObjectID)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			}
+
+			id := c.Param(param)
+			if !primitive.IsValidObjectID(id) {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid client ID")
+			}
+
+			client, err := load(id)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusNotFound, "Client not found")
+			}
+
+			if err := service.AuthorizeClient(userID, client, action); err != nil {
+				if errors.Is(err, authz.ErrInsufficientScope) {
+					return echo.NewHTTPError(http.StatusForbidden, "Your access to this client doesn't allow this action")
+				}
+				return echo.NewHTTPError(http.StatusNotFound, "Client not found")
+			}
+
+			c.Set(ClientContextKey, client)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package migrations
+
+import (
+	"fmt"
+	"log"
+
+	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Run applies the pending data migrations, promotes the users of adminEmails to
+// admin and makes sure the indexes used by the repositories exist. Every step is
+// idempotent so it is safe to run on each start.
+func Run(mongoRepo *db.MongoRepo, adminEmails []string) error {
+	if err := BackfillPaymentUserIDs(mongoRepo); err != nil {
+		return err
+	}
+	if err := BackfillClientVersions(mongoRepo); err != nil {
+		return err
+	}
+	if err := BackfillClientStatuses(mongoRepo); err != nil {
+		return err
+	}
+	if err := BackfillUserRoles(mongoRepo); err != nil {
+		return err
+	}
+	if err := BackfillEmailVerified(mongoRepo); err != nil {
+		return err
+	}
+	if err := BackfillLedgerBalances(mongoRepo); err != nil {
+		return err
+	}
+	if err := PromoteAdmins(mongoRepo, adminEmails); err != nil {
+		return err
+	}
+
+	return EnsureIndexes(mongoRepo)
+}
+
+// BackfillPaymentUserIDs sets the owner of payments created before they stored it,
+// copying the user_id of their client in a single aggregation
+func BackfillPaymentUserIDs(mongoRepo *db.MongoRepo) error {
+	missing := bson.M{"user_id": bson.M{"$exists": false}}
+	count, err := mongoRepo.CountDocuments("payments", missing)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	log.Printf("Backfilling user_id on %d payments...", count)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: missing}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "clients",
+			"localField":   "client_id",
+			"foreignField": "_id",
+			"as":           "client",
+		}}},
+		// Payments whose client no longer exists are left without owner
+		{{Key: "$match", Value: bson.M{"client.0": bson.M{"$exists": true}}}},
+		{{Key: "$project", Value: bson.M{
+			"user_id": bson.M{"$arrayElemAt": bson.A{"$client.user_id", 0}},
+		}}},
+		{{Key: "$merge", Value: bson.M{
+			"into":           "payments",
+			"on":             "_id",
+			"whenMatched":    "merge",
+			"whenNotMatched": "discard",
+		}}},
+	}
+
+	var result []bson.M
+	return mongoRepo.Aggregate("payments", pipeline, &result)
+}
+
+// BackfillClientVersions sets the first version on clients created before they
+// stored one, so optimistic concurrency checks can match them
+func BackfillClientVersions(mongoRepo *db.MongoRepo) error {
+	result, err := mongoRepo.UpdateMany("clients", bson.M{"version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"version": 1}})
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("Backfilled version on %d clients", result.ModifiedCount)
+	}
+	return nil
+}
+
+// BackfillClientStatuses moves clients off the legacy statuses: inactive clients
+// owe money so they are in grace, and clients stored before statuses existed
+// start as pending until their balance sets their status
+func BackfillClientStatuses(mongoRepo *db.MongoRepo) error {
+	updates := []struct {
+		filter bson.M
+		status models.ClientStatus
+	}{
+		{bson.M{"status": models.ClientStatusInactive}, models.ClientStatusGrace},
+		{bson.M{"status": bson.M{"$in": bson.A{nil, ""}}}, models.ClientStatusPending},
+	}
+
+	for _, update := range updates {
+		result, err := mongoRepo.UpdateMany("clients", update.filter, bson.M{"$set": bson.M{"status": update.status}})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount > 0 {
+			log.Printf("Backfilled status %s on %d clients", update.status, result.ModifiedCount)
+		}
+	}
+	return nil
+}
+
+// BackfillUserRoles gives the owner role to users registered before roles were
+// checked, who got the generic "user" role
+func BackfillUserRoles(mongoRepo *db.MongoRepo) error {
+	filter := bson.M{"role": bson.M{"$nin": bson.A{models.RoleAdmin, models.RoleOwner, models.RoleAssistant}}}
+	result, err := mongoRepo.UpdateMany("users", filter, bson.M{"$set": bson.M{"role": models.RoleOwner}})
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("Backfilled role on %d users", result.ModifiedCount)
+	}
+	return nil
+}
+
+// BackfillEmailVerified marks users registered before emails were verified as
+// verified, so they keep making changes without following a link
+func BackfillEmailVerified(mongoRepo *db.MongoRepo) error {
+	filter := bson.M{"email_verified": bson.M{"$exists": false}}
+	result, err := mongoRepo.UpdateMany("users", filter, bson.M{"$set": bson.M{"email_verified": true}})
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("Backfilled email_verified on %d users", result.ModifiedCount)
+	}
+	return nil
+}
+
+// PromoteAdmins gives the admin role to the users with the given emails, which
+// is how the first admin is created. Only verified emails are promoted, so
+// registering with a listed email before its owner doesn't grant the role.
+func PromoteAdmins(mongoRepo *db.MongoRepo, emails []string) error {
+	if len(emails) == 0 {
+		return nil
+	}
+
+	filter := bson.M{
+		"email":          bson.M{"$in": emails},
+		"email_verified": true,
+		"role":           bson.M{"$ne": models.RoleAdmin},
+	}
+	result, err := mongoRepo.UpdateMany("users", filter, bson.M{"$set": bson.M{"role": models.RoleAdmin}})
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("Promoted %d users to admin", result.ModifiedCount)
+	}
+	return nil
+}
+
+// BackfillLedgerBalances numbers the ledger entries stored before they had a
+// sequence, in the order they were created, and stores the balance of their
+// clients, which is then kept up to date as entries are added
+func BackfillLedgerBalances(mongoRepo *db.MongoRepo) error {
+	count, err := mongoRepo.CountDocuments("ledger_entries", bson.M{"sequence": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	log.Printf("Backfilling sequence on %d ledger entries...", count)
+
+	sequences := mongo.Pipeline{
+		{{Key: "$setWindowFields", Value: bson.M{
+			"partitionBy": "$client_id",
+			"sortBy":      bson.M{"created_at": 1, "_id": 1},
+			"output":      bson.M{"sequence": bson.M{"$documentNumber": bson.M{}}},
+		}}},
+		{{Key: "$project", Value: bson.M{"sequence": 1}}},
+		{{Key: "$merge", Value: bson.M{
+			"into":           "ledger_entries",
+			"on":             "_id",
+			"whenMatched":    "merge",
+			"whenNotMatched": "discard",
+		}}},
+	}
+	var result []bson.M
+	if err := mongoRepo.Aggregate("ledger_entries", sequences, &result); err != nil {
+		return err
+	}
+
+	// The balance of a client is the running balance of its latest entry
+	balances := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "client_id", Value: 1}, {Key: "sequence", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      "$client_id",
+			"user_id":  bson.M{"$last": "$user_id"},
+			"balance":  bson.M{"$last": "$balance"},
+			"sequence": bson.M{"$last": "$sequence"},
+		}}},
+		{{Key: "$merge", Value: bson.M{
+			"into":           "ledger_balances",
+			"on":             "_id",
+			"whenMatched":    "keepExisting",
+			"whenNotMatched": "insert",
+		}}},
+	}
+	return mongoRepo.Aggregate("ledger_entries", balances, &result)
+}
+
+// EnsureIndexes creates the indexes backing the listings, ledger and scheduled
+// jobs. Some unique indexes guard against double charges, so failing to create
+// any of them is an error rather than a slower query.
+func EnsureIndexes(mongoRepo *db.MongoRepo) error {
+	indexes := map[string][]mongo.IndexModel{
+		"clients": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}}},
+			{Keys: bson.D{{Key: "deleted_at", Value: 1}}},
+		},
+		"payments": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "payment_date", Value: -1}}},
+			{Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "payment_date", Value: -1}}},
+		},
+		"ledger_entries": {
+			{Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "sequence", Value: -1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "kind", Value: 1}, {Key: "period", Value: 1}}},
+			// A period is charged once, even when the charge job and a payment race
+			{Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "period", Value: 1}}, Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"kind": models.LedgerKindCharge}).
+				SetName("client_id_1_period_1_charge")},
+			{Keys: bson.D{{Key: "reference_id", Value: 1}}},
+		},
+		"client_status_history": {
+			{Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		"discounts": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "active", Value: 1}}},
+		},
+		"late_fee_policies": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		"invitations": {
+			{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		"grants": {
+			{Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "collaborator_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "collaborator_id", Value: 1}}},
+		},
+		"refresh_tokens": {
+			{Keys: bson.D{{Key: "jti", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "family", Value: 1}}},
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},
+			// Expired tokens are removed by MongoDB
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"account_tokens": {
+			{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}}},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"api_keys": {
+			{Keys: bson.D{{Key: "key_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		"users": {
+			{Keys: bson.D{{Key: "email", Value: 1}}},
+		},
+	}
+
+	for collection, collectionIndexes := range indexes {
+		if err := mongoRepo.CreateIndexes(collection, collectionIndexes); err != nil {
+			return fmt.Errorf("creating indexes on %s: %w", collection, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TokenPurpose tells what an account token can be used for
+type TokenPurpose string
+
+const (
+	TokenPurposeVerifyEmail   TokenPurpose = "verify_email"
+	TokenPurposeResetPassword TokenPurpose = "reset_password"
+)
+
+const (
+	EmailVerificationExpiration = 24 * time.Hour // 1 day
+	PasswordResetExpiration     = time.Hour      // 1 hour
+)
+
+// AccountToken is a single use token emailed to a user to verify their email or
+// reset their password. Only the hash of the token is stored.
+type AccountToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Purpose   TokenPurpose       `bson:"purpose" json:"purpose"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty" json:"used_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+func NewAccountToken(purpose TokenPurpose, userID primitive.ObjectID, tokenHash string, expiresAt time.Time) *AccountToken {
+	return &AccountToken{
+		Purpose:   purpose,
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+}
@@ -12,10 +12,14 @@ type Client struct {
 	Name            string             `bson:"name" json:"name"`
 	CellPhone       string             `bson:"cell_phone" json:"cell_phone"`
 	DayToPay        int                `bson:"day_to_pay" json:"day_to_pay"`
-	Status          string             `bson:"status" json:"status"` // 'active', 'inactive'
+	Status          ClientStatus       `bson:"status" json:"status"`
 	LastPaymentDate time.Time          `bson:"last_payment_date" json:"last_payment_date"`
+	PaidThrough     time.Time          `bson:"paid_through,omitempty" json:"paid_through,omitempty"` // End of the last prepaid billing period
 	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
+	DeletedAt       *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"` // Set when the client is in the trash
+	Purging         bool               `bson:"purging,omitempty" json:"-"`                       // Set while the client is being permanently removed
+	Version         int64              `bson:"version" json:"version"`                           // Incremented whenever the client's details change
 }
 
 // NewClient creates a new client with the provided information
@@ -25,9 +29,26 @@ func NewClient(userID primitive.ObjectID, name, cellPhone string, dayToPay int)
 		Name:            name,
 		CellPhone:       cellPhone,
 		DayToPay:        dayToPay,
-		Status:          "inactive",
+		Status:          ClientStatusPending,
 		LastPaymentDate: time.Time{},
 		CreatedAt:       time.Now(),
 		UpdatedAt:       time.Now(),
+		Version:         1,
 	}
 }
+
+// NextUnpaidPeriodStart returns the start of the first billing period not yet
+// covered by a payment: the current period, or the one after PaidThrough if the
+// client prepaid
+func (c *Client) NextUnpaidPeriodStart(now time.Time) time.Time {
+	if c.PaidThrough.After(now) {
+		next := c.PaidThrough.In(now.Location()).Add(time.Second)
+		return time.Date(next.Year(), next.Month(), 1, 0, 0, 0, 0, now.Location())
+	}
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+}
+
+// IsPaidFor reports whether the billing period containing t is already covered by a payment
+func (c *Client) IsPaidFor(t time.Time) bool {
+	return !c.PaidThrough.IsZero() && !c.PaidThrough.Before(t)
+}
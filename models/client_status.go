@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ClientStatus represents the lifecycle state of a client
+type ClientStatus string
+
+const (
+	ClientStatusPending   ClientStatus = "pending"   // Initial state, the client hasn't paid yet
+	ClientStatusActive    ClientStatus = "active"    // The client is up to date with its payments
+	ClientStatusGrace     ClientStatus = "grace"     // The client owes money and receives reminders
+	ClientStatusSuspended ClientStatus = "suspended" // Paused by the owner, no charges or reminders
+	ClientStatusCancelled ClientStatus = "cancelled" // Cancelled by the owner, payment history is kept
+	ClientStatusArchived  ClientStatus = "archived"  // Final state for cancelled clients
+
+	// ClientStatusInactive is the legacy status of clients that hadn't paid,
+	// it is handled like grace until those documents are updated
+	ClientStatusInactive ClientStatus = "inactive"
+)
+
+// Errors returned by ValidateStateTransition
+var (
+	ErrInvalidClientStatus = errors.New("invalid client status")
+	ErrInvalidTransition   = errors.New("invalid state transition")
+)
+
+// clientStatusTransitions lists the states each state can move to
+var clientStatusTransitions = map[ClientStatus][]ClientStatus{
+	ClientStatusPending:   {ClientStatusActive, ClientStatusGrace, ClientStatusSuspended, ClientStatusCancelled},
+	ClientStatusActive:    {ClientStatusGrace, ClientStatusSuspended, ClientStatusCancelled},
+	ClientStatusGrace:     {ClientStatusActive, ClientStatusSuspended, ClientStatusCancelled},
+	ClientStatusSuspended: {ClientStatusActive, ClientStatusGrace, ClientStatusCancelled},
+	ClientStatusCancelled: {ClientStatusActive, ClientStatusGrace, ClientStatusArchived},
+	ClientStatusArchived:  {},
+}
+
+// normalize maps legacy values to their current equivalent
+func (s ClientStatus) normalize() ClientStatus {
+	if s == ClientStatusInactive {
+		return ClientStatusGrace
+	}
+	return s
+}
+
+// IsValid reports whether the status is one of the known states
+func (s ClientStatus) IsValid() bool {
+	_, ok := clientStatusTransitions[s.normalize()]
+	return ok
+}
+
+// IsBillable reports whether clients in this state are charged, reminded and
+// have their status updated automatically from their balance
+func (s ClientStatus) IsBillable() bool {
+	switch s.normalize() {
+	case ClientStatusPending, ClientStatusActive, ClientStatusGrace:
+		return true
+	}
+	return false
+}
+
+// ValidateStateTransition checks if the client can move to the new status
+func (c *Client) ValidateStateTransition(newStatus ClientStatus) error {
+	if !newStatus.IsValid() || newStatus == ClientStatusInactive {
+		return fmt.Errorf("%w: %s", ErrInvalidClientStatus, newStatus)
+	}
+
+	current := c.Status.normalize()
+	for _, allowed := range clientStatusTransitions[current] {
+		if allowed == newStatus {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s cannot transition to %s", ErrInvalidTransition, current, newStatus)
+}
+
+// ClientStatusForBalance returns the status a billable client should have given
+// its ledger balance: active when what it owes is covered, grace otherwise, so
+// reminders only go to clients with pending debt
+func ClientStatusForBalance(balance float64) ClientStatus {
+	if balance >= 0 {
+		return ClientStatusActive
+	}
+	return ClientStatusGrace
+}
+
+// StatusForBalance returns the status a billable client moves to given its
+// ledger balance, and whether that is a change. Legacy statuses are compared by
+// their current equivalent, and clients that aren't billable never change.
+func (c *Client) StatusForBalance(balance float64) (ClientStatus, bool) {
+	if !c.Status.IsBillable() {
+		return c.Status, false
+	}
+	status := ClientStatusForBalance(balance)
+	return status, c.Status.normalize() != status
+}
+
+// ClientStatusChange records a change in the status of a client
+type ClientStatusChange struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	ClientID  primitive.ObjectID  `bson:"client_id" json:"client_id"`
+	From      ClientStatus        `bson:"from" json:"from"`
+	To        ClientStatus        `bson:"to" json:"to"`
+	Reason    string              `bson:"reason" json:"reason"`
+	ChangedBy *primitive.ObjectID `bson:"changed_by,omitempty" json:"changed_by,omitempty"` // Empty when changed by the system
+	CreatedAt time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// NewClientStatusChange creates a new status history entry
+func NewClientStatusChange(clientID primitive.ObjectID, from, to ClientStatus, reason string, changedBy *primitive.ObjectID) *ClientStatusChange {
+	return &ClientStatusChange{
+		ClientID:  clientID,
+		From:      from,
+		To:        to,
+		Reason:    reason,
+		ChangedBy: changedBy,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+import "testing"
+
+func TestStatusForBalance(t *testing.T) {
+	tests := []struct {
+		status  ClientStatus
+		balance float64
+		want    ClientStatus
+		changed bool
+	}{
+		{ClientStatusPending, 0, ClientStatusActive, true},
+		{ClientStatusPending, -10, ClientStatusGrace, true},
+		{ClientStatusActive, 0, ClientStatusActive, false},
+		{ClientStatusActive, -10, ClientStatusGrace, true},
+		{ClientStatusGrace, -10, ClientStatusGrace, false},
+		{ClientStatusGrace, 5, ClientStatusActive, true},
+		{ClientStatusInactive, -10, ClientStatusGrace, false},
+		{ClientStatusInactive, 0, ClientStatusActive, true},
+		{ClientStatusSuspended, 0, ClientStatusSuspended, false},
+		{ClientStatusCancelled, -10, ClientStatusCancelled, false},
+	}
+
+	for _, tt := range tests {
+		client := &Client{Status: tt.status}
+		status, changed := client.StatusForBalance(tt.balance)
+		if status != tt.want || changed != tt.changed {
+			t.Errorf("%s with balance %v = %s, %v, want %s, %v", tt.status, tt.balance, status, changed, tt.want, tt.changed)
+			continue
+		}
+		if changed {
+			if err := client.ValidateStateTransition(status); err != nil {
+				t.Errorf("%s can't move to %s: %v", tt.status, status, err)
+			}
+		}
+	}
+}
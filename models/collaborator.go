@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GrantScope is the access an owner gives a collaborator to their clients
+type GrantScope string
+
+const (
+	GrantScopeRead     GrantScope = "read"     // See clients, payments and their history
+	GrantScopePayments GrantScope = "payments" // Read access, and record payments
+)
+
+// InvitationExpiration is how long an invitation can be accepted
+const InvitationExpiration = 7 * 24 * time.Hour
+
+// Invitation offers a grant to whoever registered with Email. Only the hash of
+// its token is stored, the token itself is sent in the invitation link.
+type Invitation struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID    primitive.ObjectID  `bson:"owner_id" json:"owner_id"`
+	Email      string              `bson:"email" json:"email"`
+	Scope      GrantScope          `bson:"scope" json:"scope"`
+	TokenHash  string              `bson:"token_hash" json:"-"`
+	ExpiresAt  time.Time           `bson:"expires_at" json:"expires_at"`
+	AcceptedBy *primitive.ObjectID `bson:"accepted_by,omitempty" json:"accepted_by,omitempty"`
+	AcceptedAt *time.Time          `bson:"accepted_at,omitempty" json:"accepted_at,omitempty"`
+	CreatedAt  time.Time           `bson:"created_at" json:"created_at"`
+}
+
+func NewInvitation(ownerID primitive.ObjectID, email string, scope GrantScope, tokenHash string) *Invitation {
+	now := time.Now()
+	return &Invitation{
+		OwnerID:   ownerID,
+		Email:     email,
+		Scope:     scope,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(InvitationExpiration),
+		CreatedAt: now,
+	}
+}
+
+// Grant gives a collaborator scoped access to every client of an owner
+type Grant struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID           primitive.ObjectID `bson:"owner_id" json:"owner_id"`
+	CollaboratorID    primitive.ObjectID `bson:"collaborator_id" json:"collaborator_id"`
+	CollaboratorEmail string             `bson:"collaborator_email" json:"collaborator_email"`
+	Scope             GrantScope         `bson:"scope" json:"scope"`
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time          `bson:"updated_at" json:"updated_at"`
+}
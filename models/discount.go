@@ -0,0 +1,132 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DiscountType represents how a discount reduces the amount due
+type DiscountType string
+
+const (
+	DiscountTypePercentage DiscountType = "percentage" // Value is a percentage (0-100] of the price
+	DiscountTypeFixed      DiscountType = "fixed"      // Value is a fixed amount taken from the price
+)
+
+// Discount is a rule that reduces the charge of a billing period. When ClientID
+// is nil the discount applies to every client of the owner (the whole plan), and
+// its cycles are counted for each client in ClientCyclesUsed.
+type Discount struct {
+	ID               primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID           primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	ClientID         *primitive.ObjectID `bson:"client_id,omitempty" json:"client_id,omitempty"`
+	Type             DiscountType        `bson:"type" json:"type"`
+	Value            float64             `bson:"value" json:"value"`
+	Cycles           int                 `bson:"cycles" json:"cycles"` // Number of billing periods it applies to, 0 means no limit
+	CyclesUsed       int                 `bson:"cycles_used" json:"cycles_used"`
+	ClientCyclesUsed map[string]int      `bson:"client_cycles_used,omitempty" json:"client_cycles_used,omitempty"` // Cycles used by each client of a plan discount, by client ID
+	Description      string              `bson:"description" json:"description"`
+	Active           bool                `bson:"active" json:"active"`
+	CreatedBy        primitive.ObjectID  `bson:"created_by" json:"created_by"`
+	DeactivatedBy    *primitive.ObjectID `bson:"deactivated_by,omitempty" json:"deactivated_by,omitempty"`
+	DeactivatedAt    *time.Time          `bson:"deactivated_at,omitempty" json:"deactivated_at,omitempty"`
+	CreatedAt        time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt        time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+// AppliedDiscount describes the reduction a discount produced on a charge
+type AppliedDiscount struct {
+	DiscountID  primitive.ObjectID `json:"discount_id"`
+	Description string             `json:"description"`
+	Amount      float64            `json:"amount"`
+}
+
+// NewDiscount creates a new active discount
+func NewDiscount(userID primitive.ObjectID, clientID *primitive.ObjectID, discountType DiscountType, value float64, cycles int, description string, createdBy primitive.ObjectID) *Discount {
+	now := time.Now()
+	return &Discount{
+		UserID:      userID,
+		ClientID:    clientID,
+		Type:        discountType,
+		Value:       value,
+		Cycles:      cycles,
+		Description: description,
+		Active:      true,
+		CreatedBy:   createdBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// Validate checks the discount type, value and duration
+func (d *Discount) Validate() error {
+	switch d.Type {
+	case DiscountTypePercentage:
+		if d.Value <= 0 || d.Value > 100 {
+			return errors.New("percentage discounts must be greater than 0 and at most 100")
+		}
+	case DiscountTypeFixed:
+		if d.Value <= 0 {
+			return errors.New("fixed discounts must be greater than 0")
+		}
+	default:
+		return errors.New("invalid discount type: must be percentage or fixed")
+	}
+
+	if d.Cycles < 0 {
+		return errors.New("cycles cannot be negative")
+	}
+
+	return nil
+}
+
+// ForClient returns the discount as it applies to a client: plan discounts
+// count the cycles that client used
+func (d Discount) ForClient(clientID primitive.ObjectID) Discount {
+	if d.ClientID == nil {
+		d.CyclesUsed = d.ClientCyclesUsed[clientID.Hex()]
+	}
+	return d
+}
+
+// IsAvailable reports whether the discount can still be applied to a billing period
+func (d *Discount) IsAvailable() bool {
+	return d.Active && (d.Cycles == 0 || d.CyclesUsed < d.Cycles)
+}
+
+// AmountOff returns how much the discount takes from the given amount
+func (d *Discount) AmountOff(amount float64) float64 {
+	var off float64
+	switch d.Type {
+	case DiscountTypePercentage:
+		off = amount * d.Value / 100
+	case DiscountTypeFixed:
+		off = d.Value
+	}
+	return math.Min(off, amount)
+}
+
+// ApplyDiscounts applies the available discounts in order to the base amount and
+// returns the resulting amount with the detail of each reduction. The amount never
+// goes below 0.
+func ApplyDiscounts(base float64, discounts []Discount) (float64, []AppliedDiscount) {
+	amount := base
+	applied := []AppliedDiscount{}
+	for _, discount := range discounts {
+		if !discount.IsAvailable() || amount <= 0 {
+			continue
+		}
+
+		off := discount.AmountOff(amount)
+		amount -= off
+		applied = append(applied, AppliedDiscount{
+			DiscountID:  discount.ID,
+			Description: discount.Description,
+			Amount:      off,
+		})
+	}
+	return amount, applied
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPlanDiscountCountsCyclesPerClient(t *testing.T) {
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+	discount := Discount{
+		Type:             DiscountTypePercentage,
+		Value:            10,
+		Cycles:           3,
+		Active:           true,
+		ClientCyclesUsed: map[string]int{first.Hex(): 3},
+	}
+
+	forFirst := discount.ForClient(first)
+	if forFirst.IsAvailable() {
+		t.Error("discount available to a client that used all its cycles")
+	}
+	forSecond := discount.ForClient(second)
+	if !forSecond.IsAvailable() {
+		t.Error("discount unavailable to a client that didn't use it")
+	}
+	if amount, _ := ApplyDiscounts(100, []Discount{forSecond}); amount != 90 {
+		t.Errorf("amount = %v, want 90", amount)
+	}
+}
+
+func TestClientDiscountKeepsItsCycles(t *testing.T) {
+	client := primitive.NewObjectID()
+	discount := Discount{ClientID: &client, Type: DiscountTypeFixed, Value: 5, Cycles: 2, CyclesUsed: 2, Active: true}
+
+	forClient := discount.ForClient(client)
+	if forClient.IsAvailable() {
+		t.Error("client discount available after using all its cycles")
+	}
+}
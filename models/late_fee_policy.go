@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LateFeeType represents how a late fee is calculated
+type LateFeeType string
+
+const (
+	LateFeeTypeFlat       LateFeeType = "flat"       // Value is a fixed amount
+	LateFeeTypePercentage LateFeeType = "percentage" // Value is a percentage of the amount owed
+)
+
+// LateFeePolicy defines when and how much an owner charges clients that stay
+// unpaid after the reminder window. A fee is charged AfterDays days after the
+// window ends and again every AfterDays days while the client remains unpaid,
+// never exceeding CapPerPeriod in the same billing period.
+type LateFeePolicy struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Enabled      bool               `bson:"enabled" json:"enabled"`
+	Type         LateFeeType        `bson:"type" json:"type"`
+	Value        float64            `bson:"value" json:"value"`
+	AfterDays    int                `bson:"after_days" json:"after_days"`
+	CapPerPeriod float64            `bson:"cap_per_period" json:"cap_per_period"` // 0 means no cap
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+func NewLateFeePolicy(userID primitive.ObjectID, enabled bool, feeType LateFeeType, value float64, afterDays int, capPerPeriod float64) *LateFeePolicy {
+	now := time.Now()
+	return &LateFeePolicy{
+		UserID:       userID,
+		Enabled:      enabled,
+		Type:         feeType,
+		Value:        value,
+		AfterDays:    afterDays,
+		CapPerPeriod: capPerPeriod,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// Validate checks the fee type, value, delay and cap
+func (p *LateFeePolicy) Validate() error {
+	switch p.Type {
+	case LateFeeTypeFlat:
+		if p.Value <= 0 {
+			return errors.New("flat late fees must be greater than 0")
+		}
+	case LateFeeTypePercentage:
+		if p.Value <= 0 || p.Value > 100 {
+			return errors.New("percentage late fees must be greater than 0 and at most 100")
+		}
+	default:
+		return errors.New("invalid late fee type: must be flat or percentage")
+	}
+
+	if p.AfterDays < 0 {
+		return errors.New("after_days cannot be negative")
+	}
+	if p.CapPerPeriod < 0 {
+		return errors.New("cap_per_period cannot be negative")
+	}
+
+	return nil
+}
+
+// Interval returns the number of days between two consecutive fees
+func (p *LateFeePolicy) Interval() int {
+	if p.AfterDays < 1 {
+		return 1
+	}
+	return p.AfterDays
+}
+
+// FeeFor returns the fee to charge for the amount owed, given the fees already
+// charged in the same period
+func (p *LateFeePolicy) FeeFor(owed float64, chargedThisPeriod float64) float64 {
+	fee := p.Value
+	if p.Type == LateFeeTypePercentage {
+		fee = owed * p.Value / 100
+	}
+
+	if p.CapPerPeriod > 0 {
+		fee = math.Min(fee, p.CapPerPeriod-chargedThisPeriod)
+	}
+	return math.Max(fee, 0)
+}
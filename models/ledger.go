@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LedgerEntryType indicates whether an entry increases or reduces what a client owes
+type LedgerEntryType string
+
+const (
+	LedgerEntryDebit  LedgerEntryType = "debit"  // Increases what the client owes (charges)
+	LedgerEntryCredit LedgerEntryType = "credit" // Reduces what the client owes (payments, refunds, adjustments)
+)
+
+// LedgerEntryKind describes where a ledger entry comes from
+type LedgerEntryKind string
+
+const (
+	LedgerKindCharge     LedgerEntryKind = "charge"            // Amount due for a billing period
+	LedgerKindPayment    LedgerEntryKind = "payment"           // Completed payment made by the client
+	LedgerKindRefund     LedgerEntryKind = "refund"            // Charge given back to the client
+	LedgerKindAdjustment LedgerEntryKind = "adjustment"        // Manual correction made by the owner
+	LedgerKindDiscount   LedgerEntryKind = "discount"          // Reduction of a charge by a discount rule
+	LedgerKindLateFee    LedgerEntryKind = "late_fee"          // Fee charged for paying after the reminder window
+	LedgerKindFeeReverse LedgerEntryKind = "late_fee_reversal" // Late fee given back by the owner
+)
+
+// BillingPeriodLayout is the format used to identify a billing period (one per month)
+const BillingPeriodLayout = "2006-01"
+
+// LedgerEntry represents a single movement in a client's balance.
+// Balance is the running balance after the entry is applied: a positive value
+// means the client has credit available for future periods, a negative value
+// means the client owes money. Sequence orders the entries of a client as they
+// were applied to the balance.
+type LedgerEntry struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	ClientID    primitive.ObjectID  `bson:"client_id" json:"client_id"`
+	UserID      primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	Type        LedgerEntryType     `bson:"type" json:"type"`
+	Kind        LedgerEntryKind     `bson:"kind" json:"kind"`
+	Amount      float64             `bson:"amount" json:"amount"`
+	Balance     float64             `bson:"balance" json:"balance"`
+	Sequence    int64               `bson:"sequence" json:"sequence"`
+	Period      string              `bson:"period,omitempty" json:"period,omitempty"`             // Billing period (YYYY-MM) for charges
+	ReferenceID *primitive.ObjectID `bson:"reference_id,omitempty" json:"reference_id,omitempty"` // Related document, e.g. the payment or discount
+	Description string              `bson:"description,omitempty" json:"description,omitempty"`
+	CreatedBy   primitive.ObjectID  `bson:"created_by,omitempty" json:"created_by,omitempty"` // User who created a manual entry
+	CreatedAt   time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// NewLedgerEntry creates a new ledger entry for a client. The balance is
+// computed by the repository when the entry is stored.
+func NewLedgerEntry(clientID, userID primitive.ObjectID, entryType LedgerEntryType, kind LedgerEntryKind, amount float64, description string) *LedgerEntry {
+	return &LedgerEntry{
+		ClientID:    clientID,
+		UserID:      userID,
+		Type:        entryType,
+		Kind:        kind,
+		Amount:      amount,
+		Description: description,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// Validate checks that the amount is positive and the kind matches the entry type
+func (e *LedgerEntry) Validate() error {
+	if e.Amount <= 0 {
+		return errors.New("ledger entry amount must be greater than 0")
+	}
+
+	switch e.Kind {
+	case LedgerKindCharge, LedgerKindLateFee:
+		if e.Type != LedgerEntryDebit {
+			return errors.New("charges and late fees must be debit entries")
+		}
+	case LedgerKindPayment, LedgerKindRefund, LedgerKindDiscount, LedgerKindFeeReverse:
+		if e.Type != LedgerEntryCredit {
+			return errors.New("payments, refunds, discounts and reversals must be credit entries")
+		}
+	case LedgerKindAdjustment:
+		if e.Type != LedgerEntryDebit && e.Type != LedgerEntryCredit {
+			return errors.New("invalid ledger entry type")
+		}
+	default:
+		return errors.New("invalid ledger entry kind")
+	}
+
+	return nil
+}
+
+// SignedAmount returns the effect of the entry on the balance
+func (e *LedgerEntry) SignedAmount() float64 {
+	if e.Type == LedgerEntryDebit {
+		return -e.Amount
+	}
+	return e.Amount
+}
+
+// BillingPeriod returns the billing period identifier for the given time
+func BillingPeriod(t time.Time) string {
+	return t.Format(BillingPeriodLayout)
+}
@@ -18,20 +18,26 @@ const (
 
 // Payment represents a payment transaction
 type Payment struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Amount      float64            `bson:"amount" json:"amount"`
-	PaymentDate time.Time          `bson:"payment_date" json:"payment_date"`
-	ClientID    primitive.ObjectID `bson:"client_id" json:"client_id"`
-	Status      PaymentStatus      `bson:"status" json:"status"`
-	Error       string             `bson:"error,omitempty" json:"error,omitempty"` // Stores error message if status is rejected
-	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Amount         float64            `bson:"amount" json:"amount"`
+	PaymentDate    time.Time          `bson:"payment_date" json:"payment_date"`
+	ClientID       primitive.ObjectID `bson:"client_id" json:"client_id"`
+	UserID         primitive.ObjectID `bson:"user_id" json:"user_id"` // Owner of the client, to query an owner's payments at once
+	Status         PaymentStatus      `bson:"status" json:"status"`
+	Error          string             `bson:"error,omitempty" json:"error,omitempty"`                     // Stores error message if status is rejected
+	Months         int                `bson:"months,omitempty" json:"months,omitempty"`                   // Number of billing periods covered
+	CoveredFrom    string             `bson:"covered_from,omitempty" json:"covered_from,omitempty"`       // First billing period covered (YYYY-MM)
+	CoveredThrough string             `bson:"covered_through,omitempty" json:"covered_through,omitempty"` // Last billing period covered (YYYY-MM)
+	Partial        bool               `bson:"partial,omitempty" json:"partial,omitempty"`                 // Below the price of its period, credited without covering it
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
 // NewPayment creates a new payment with the provided information
-func NewPayment(clientID primitive.ObjectID, amount float64) *Payment {
+func NewPayment(userID, clientID primitive.ObjectID, amount float64) *Payment {
 	return &Payment{
 		ClientID:    clientID,
+		UserID:      userID,
 		Amount:      amount,
 		PaymentDate: time.Now(),
 		Status:      PaymentStatusProcessing, // Initial state
@@ -40,6 +46,47 @@ func NewPayment(clientID primitive.ObjectID, amount float64) *Payment {
 	}
 }
 
+// SetCoverage records the billing periods paid by this payment, starting at the
+// period that contains start and spanning the given number of months
+func (p *Payment) SetCoverage(start time.Time, months int) {
+	first := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location())
+	p.Months = months
+	p.CoveredFrom = BillingPeriod(first)
+	p.CoveredThrough = BillingPeriod(first.AddDate(0, months-1, 0))
+}
+
+// MarkPartial records that the payment doesn't pay for a whole billing period:
+// it is credited to the client's balance without covering any period
+func (p *Payment) MarkPartial() {
+	p.Partial = true
+	p.Months = 0
+	p.CoveredFrom = ""
+	p.CoveredThrough = ""
+}
+
+// CoveredPeriods returns every billing period paid by this payment
+func (p *Payment) CoveredPeriods() []string {
+	first, err := time.Parse(BillingPeriodLayout, p.CoveredFrom)
+	if err != nil {
+		return nil
+	}
+
+	periods := make([]string, 0, p.Months)
+	for i := 0; i < p.Months; i++ {
+		periods = append(periods, BillingPeriod(first.AddDate(0, i, 0)))
+	}
+	return periods
+}
+
+// PaidThrough returns the last moment of the last billing period covered by this payment
+func (p *Payment) PaidThrough(loc *time.Location) time.Time {
+	last, err := time.ParseInLocation(BillingPeriodLayout, p.CoveredThrough, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return last.AddDate(0, 1, 0).Add(-time.Second)
+}
+
 // ValidateStateTransition checks if a state transition is valid
 func (p *Payment) ValidateStateTransition(newStatus PaymentStatus) error {
 	switch p.Status {
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken records an issued refresh token by its jti. Tokens rotated from
+// the same login share a Family; a token can be used once, and using it again
+// revokes the whole family since it means the token was stolen.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	JTI       string             `bson:"jti" json:"jti"`
+	Family    string             `bson:"family" json:"family"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty" json:"used_at,omitempty"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+func NewRefreshToken(jti, family string, userID primitive.ObjectID, expiresAt time.Time) *RefreshToken {
+	return &RefreshToken{
+		JTI:       jti,
+		Family:    family,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+}
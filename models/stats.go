@@ -0,0 +1,22 @@
+package models
+
+// Stats summarizes an owner's collections for the dashboard
+type Stats struct {
+	From            string          `json:"from"`
+	To              string          `json:"to"`
+	Collected       float64         `json:"collected"`         // Completed payments in the range
+	Expected        float64         `json:"expected"`          // Price of every billable client for each month in the range
+	PaymentsCount   int64           `json:"payments_count"`    // Completed payments in the range
+	AverageDaysLate float64         `json:"average_days_late"` // Average days after the due date, on-time payments count as 0
+	PaidClients     int64           `json:"paid_clients"`
+	UnpaidClients   int64           `json:"unpaid_clients"`  // Owing but still before their payment day this month
+	OverdueClients  int64           `json:"overdue_clients"` // Owing and past their payment day this month
+	BillableClients int64           `json:"billable_clients"`
+	MonthlyRevenue  []MonthlyAmount `json:"monthly_revenue"` // Last 12 months up to the end of the range
+}
+
+// MonthlyAmount is the amount collected in a billing period
+type MonthlyAmount struct {
+	Period string  `json:"period"`
+	Amount float64 `json:"amount"`
+}
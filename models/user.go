@@ -7,16 +7,35 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Roles of a user, they decide the permissions granted by auth.HasPermission
+const (
+	RoleAdmin     = "admin"     // Manages users, besides their own clients
+	RoleOwner     = "owner"     // Manages their own clients and payments
+	RoleAssistant = "assistant" // Read only access
+)
+
+// Roles lists the valid roles
+var Roles = []string{RoleAdmin, RoleOwner, RoleAssistant}
+
 // En models/user.go
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Name      string             `bson:"name" json:"name"`
-	Username  string             `bson:"username" json:"username"`
-	Email     string             `bson:"email" json:"email"`
-	Password  string             `bson:"password" json:"-"`
-	Role      string             `bson:"role" json:"role"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name            string             `bson:"name" json:"name"`
+	Username        string             `bson:"username" json:"username"`
+	Email           string             `bson:"email" json:"email"`
+	Password        string             `bson:"password" json:"-"`
+	Role            string             `bson:"role" json:"role"`
+	Disabled        bool               `bson:"disabled" json:"disabled"`             // Disabled users can't log in or refresh their tokens
+	EmailVerified   bool               `bson:"email_verified" json:"email_verified"` // Unverified users can only read until they follow the emailed link
+	EmailVerifiedAt *time.Time         `bson:"email_verified_at,omitempty" json:"email_verified_at,omitempty"`
+	// Two-factor authentication with the codes of an authenticator app (TOTP)
+	TwoFactorEnabled  bool      `bson:"two_factor_enabled" json:"two_factor_enabled"`
+	TOTPSecret        string    `bson:"totp_secret,omitempty" json:"-"`
+	TOTPPendingSecret string    `bson:"totp_pending_secret,omitempty" json:"-"` // Set during enrollment, until a code confirms it
+	TOTPLastStep      int64     `bson:"totp_last_step,omitempty" json:"-"`      // Time step of the last code used, so codes can't be replayed
+	RecoveryCodes     []string  `bson:"recovery_codes,omitempty" json:"-"`      // Hashes of the unused recovery codes
+	CreatedAt         time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time `bson:"updated_at" json:"updated_at"`
 }
 
 // HashPassword hashes the user's password
@@ -39,7 +58,7 @@ func NewUser(username, email, password string) (*User, error) {
 	user := &User{
 		Username:  username,
 		Email:     email,
-		Role:      "user", // Default role
+		Role:      RoleOwner, // Default role
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -0,0 +1,76 @@
+package notifications
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// EmailSender sends emails to the users of the app, such as the email
+// verification and password reset links
+type EmailSender interface {
+	SendEmail(to, subject, body string) error
+}
+
+// SMTPService sends plain text emails through an SMTP server
+type SMTPService struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPService creates an SMTPService. The username and password may be empty
+// for servers that don't require authentication.
+func NewSMTPService(host, port, username, password, from string) *SMTPService {
+	if port == "" {
+		port = "587"
+	}
+	return &SMTPService{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+// SendEmail sends a plain text email
+func (s *SMTPService) SendEmail(to, subject, body string) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return fmt.Errorf("invalid email header")
+	}
+
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+
+	message := strings.Join([]string{
+		"From: " + s.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(net.JoinHostPort(s.host, s.port), auth, s.from, []string{to}, []byte(message)); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+	return nil
+}
+
+// LogEmailSender writes emails to the log instead of sending them, for
+// development environments without an SMTP server
+type LogEmailSender struct{}
+
+// SendEmail logs the email
+func (LogEmailSender) SendEmail(to, subject, body string) error {
+	log.Printf("Email to %s: %s\n%s", to, subject, body)
+	return nil
+}
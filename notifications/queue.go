@@ -0,0 +1,73 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github/Rubncal04/youtube-premium/models"
+)
+
+// DefaultQueueSize is how many messages can wait to be sent by a Queue
+const DefaultQueueSize = 1000
+
+// ErrQueueFull is returned when a message can't be queued because too many are
+// already waiting to be sent
+var ErrQueueFull = errors.New("too many messages waiting to be sent, try again later")
+
+type queuedMessage struct {
+	client  models.Client
+	message string
+}
+
+// Queue sends messages in the background through another NotificationService,
+// so requests sending many messages don't wait for each of them. Messages that
+// fail to send are logged.
+type Queue struct {
+	service  NotificationService
+	messages chan queuedMessage
+	done     chan struct{}
+}
+
+// NewQueue creates a Queue holding up to size messages and starts sending them
+func NewQueue(service NotificationService, size int) *Queue {
+	q := &Queue{
+		service:  service,
+		messages: make(chan queuedMessage, size),
+		done:     make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// SendReminder queues a message for the client, returning ErrQueueFull when the
+// queue has no room left
+func (q *Queue) SendReminder(client models.Client, message string) error {
+	select {
+	case q.messages <- queuedMessage{client: client, message: message}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	for queued := range q.messages {
+		if err := q.service.SendReminder(queued.client, queued.message); err != nil {
+			log.Printf("Error sending queued message to client %s: %v", queued.client.Name, err)
+		}
+	}
+}
+
+// Close stops taking messages and waits until the queued ones are sent or ctx is
+// done. SendReminder must not be called after Close.
+func (q *Queue) Close(ctx context.Context) error {
+	close(q.messages)
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
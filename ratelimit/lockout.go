@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github/Rubncal04/youtube-premium/cache"
+)
+
+// LockoutPolicy locks an account out after Threshold failed logins in a row,
+// for Duration at first and doubling with each further failure up to
+// MaxDuration. The zero LockoutPolicy never locks accounts out.
+type LockoutPolicy struct {
+	Threshold   int
+	Duration    time.Duration
+	MaxDuration time.Duration
+}
+
+// lockFor returns how long an account is locked out after a number of failures
+func (p LockoutPolicy) lockFor(failures int64) time.Duration {
+	if p.Threshold <= 0 || failures < int64(p.Threshold) {
+		return 0
+	}
+	lock := p.Duration
+	for i := int64(p.Threshold); i < failures && lock < p.MaxDuration; i++ {
+		lock *= 2
+	}
+	if p.MaxDuration > 0 && lock > p.MaxDuration {
+		lock = p.MaxDuration
+	}
+	return lock
+}
+
+// LoginGuard protects the accounts from brute force: it limits the login
+// attempts on each account from each IP and locks that pair out progressively
+// after repeated failures. Failures from one IP don't lock the account out for
+// the other IPs, so guessing someone's password can't keep them from logging
+// in. Accounts are identified by the email sent, registered or not, so the
+// responses don't tell which emails exist.
+type LoginGuard struct {
+	limiter *Limiter
+	limit   Limit
+	policy  LockoutPolicy
+}
+
+func NewLoginGuard(limiter *Limiter, limit Limit, policy LockoutPolicy) *LoginGuard {
+	return &LoginGuard{limiter: limiter, limit: limit, policy: policy}
+}
+
+// Check counts a login attempt on an account from an IP. It returns how long
+// to wait before trying again when the pair is locked out or over the limit,
+// zero when the attempt may proceed.
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) time.Duration {
+	attempt := attemptKey(email, ip)
+
+	var lockedUntil int64
+	if g.limiter.get(ctx, cache.GenerateKey("lockout:until", attempt), &lockedUntil) {
+		if wait := time.Unix(lockedUntil, 0).Sub(g.limiter.now()); wait > 0 {
+			return wait
+		}
+	}
+
+	result := g.limiter.Allow(ctx, cache.GenerateKey("login", attempt), g.limit)
+	if !result.Allowed {
+		return result.RetryAfter
+	}
+	return 0
+}
+
+// Fail records a failed login on an account from an IP and locks the pair out
+// once the failures reach the threshold of the policy. Failures are forgotten
+// after MaxDuration without a new one.
+func (g *LoginGuard) Fail(ctx context.Context, email, ip string) {
+	if g.policy.Threshold <= 0 {
+		return
+	}
+	attempt := attemptKey(email, ip)
+
+	failures := g.limiter.increment(ctx, cache.GenerateKey("lockout:failures", attempt), g.policy.MaxDuration)
+	if lock := g.policy.lockFor(failures); lock > 0 {
+		until := g.limiter.now().Add(lock)
+		g.limiter.set(ctx, cache.GenerateKey("lockout:until", attempt), until.Unix(), lock)
+	}
+}
+
+// Succeed forgets the failures of an account from an IP after a successful login
+func (g *LoginGuard) Succeed(ctx context.Context, email, ip string) {
+	attempt := attemptKey(email, ip)
+	g.limiter.delete(ctx, cache.GenerateKey("lockout:failures", attempt))
+	g.limiter.delete(ctx, cache.GenerateKey("lockout:until", attempt))
+}
+
+// attemptKey identifies the login attempts on an account from an IP
+func attemptKey(email, ip string) string {
+	return strings.ToLower(strings.TrimSpace(email)) + ":" + ip
+}
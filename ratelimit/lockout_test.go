@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLockFor(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 5, Duration: time.Minute, MaxDuration: time.Hour}
+	tests := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{0, 0},
+		{4, 0},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{7, 4 * time.Minute},
+		{10, 32 * time.Minute},
+		{11, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := policy.lockFor(tt.failures); got != tt.want {
+			t.Errorf("lockFor(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	if got := (LockoutPolicy{}).lockFor(100); got != 0 {
+		t.Errorf("the zero policy locked out for %v", got)
+	}
+}
+
+func TestLoginGuardLocksOutPerIP(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	guard := NewLoginGuard(testLimiter(&now), Limit{}, LockoutPolicy{Threshold: 3, Duration: time.Minute, MaxDuration: time.Hour})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if wait := guard.Check(ctx, "Owner@example.com", "10.0.0.1"); wait > 0 {
+			t.Fatalf("attempt %d was locked out", i+1)
+		}
+		guard.Fail(ctx, "Owner@example.com", "10.0.0.1")
+	}
+
+	if wait := guard.Check(ctx, "owner@example.com", "10.0.0.1"); wait != time.Minute {
+		t.Errorf("locked out for %v, want 1m", wait)
+	}
+	if wait := guard.Check(ctx, "owner@example.com", "10.0.0.2"); wait > 0 {
+		t.Errorf("another IP was locked out for %v", wait)
+	}
+
+	guard.Succeed(ctx, "owner@example.com", "10.0.0.1")
+	if wait := guard.Check(ctx, "owner@example.com", "10.0.0.1"); wait > 0 {
+		t.Errorf("still locked out for %v after a successful login", wait)
+	}
+}
+
+func TestLoginGuardLimitsAttempts(t *testing.T) {
+	now := time.Unix(0, 0).Add(100 * time.Minute)
+	guard := NewLoginGuard(testLimiter(&now), Limit{Requests: 2, Window: time.Minute}, LockoutPolicy{})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if wait := guard.Check(ctx, "owner@example.com", "10.0.0.1"); wait > 0 {
+			t.Fatalf("attempt %d was limited", i+1)
+		}
+	}
+	if wait := guard.Check(ctx, "owner@example.com", "10.0.0.1"); wait == 0 {
+		t.Error("attempt over the limit was allowed")
+	}
+	if wait := guard.Check(ctx, "owner@example.com", "10.0.0.2"); wait > 0 {
+		t.Error("attempt from another IP was limited")
+	}
+}
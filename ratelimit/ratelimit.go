@@ -0,0 +1,168 @@
+// Package ratelimit counts requests in sliding windows stored in the shared
+// cache, so every instance enforces the same limits, and protects logins with a
+// progressive lockout.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github/Rubncal04/youtube-premium/cache"
+)
+
+// Limit allows a number of requests per window. The zero Limit allows every request.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// ParseLimit parses a limit written as requests/window, such as "20/1m"
+func ParseLimit(value string) (Limit, error) {
+	requests, window, ok := strings.Cut(value, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q, expected requests/window such as 20/1m", value)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n < 0 {
+		return Limit{}, fmt.Errorf("invalid request count in limit %q", value)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid window in limit %q", value)
+	}
+	return Limit{Requests: n, Window: d}, nil
+}
+
+// Config holds the limits applied by the routes
+type Config struct {
+	Auth    Limit         // Per IP, on the public authentication routes
+	Login   Limit         // Per account and IP, on login attempts
+	API     Limit         // Per user, on the /api/v1 routes
+	Lockout LockoutPolicy // Failed logins per account and IP
+}
+
+// DefaultConfig returns the limits used when they aren't configured
+func DefaultConfig() Config {
+	return Config{
+		Auth:    Limit{Requests: 20, Window: time.Minute},
+		Login:   Limit{Requests: 10, Window: 15 * time.Minute},
+		API:     Limit{Requests: 300, Window: time.Minute},
+		Lockout: LockoutPolicy{Threshold: 5, Duration: time.Minute, MaxDuration: time.Hour},
+	}
+}
+
+func (l Limit) enabled() bool {
+	return l.Requests > 0 && l.Window > 0
+}
+
+// Result is the outcome of counting a request
+type Result struct {
+	Allowed    bool
+	Remaining  int           // Requests left in the window
+	RetryAfter time.Duration // Set when the request isn't allowed
+}
+
+// Limiter counts requests per key with a sliding window counter: the count of
+// the current fixed window plus the count of the previous one weighted by how
+// much of it the sliding window still covers.
+//
+// Counters are stored in the shared cache, and in memory while Redis is down or
+// not configured, in which case each instance counts on its own.
+type Limiter struct {
+	cache    cache.Cache
+	fallback *cache.MemoryCache
+	now      func() time.Time
+}
+
+// NewLimiter creates a limiter, shared is nil when Redis isn't available
+func NewLimiter(shared cache.Cache) *Limiter {
+	return &Limiter{cache: shared, fallback: cache.NewMemoryCache(), now: time.Now}
+}
+
+// Allow counts a request for a key and reports whether it is within the limit.
+// Rejected requests are counted too, so clients that keep retrying stay limited.
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) Result {
+	if !limit.enabled() {
+		return Result{Allowed: true, Remaining: math.MaxInt32}
+	}
+
+	now := l.now()
+	start := now.Truncate(limit.Window)
+	currentKey := cache.GenerateKey("ratelimit", key, start.Unix())
+	previousKey := cache.GenerateKey("ratelimit", key, start.Add(-limit.Window).Unix())
+
+	// Counters live for two windows, the second one as the previous window
+	current := l.increment(ctx, currentKey, 2*limit.Window)
+	var previous int64
+	l.get(ctx, previousKey, &previous)
+
+	elapsed := float64(now.Sub(start)) / float64(limit.Window)
+	estimate := float64(previous)*(1-elapsed) + float64(current)
+	allowed := estimate <= float64(limit.Requests)
+
+	result := Result{Allowed: allowed, Remaining: max(limit.Requests-int(math.Ceil(estimate)), 0)}
+	if !allowed {
+		result.RetryAfter = retryAfter(limit, now.Sub(start), previous, current)
+	}
+	return result
+}
+
+// retryAfter returns how long until the estimate of the sliding window drops to
+// the limit again, assuming no more requests arrive
+func retryAfter(limit Limit, elapsed time.Duration, previous, current int64) time.Duration {
+	requests := int64(limit.Requests)
+	if current > requests || previous == 0 {
+		// Not before the current window becomes the previous one
+		return limit.Window - elapsed
+	}
+	// previous*(1 - t/window) + current <= requests
+	needed := 1 - float64(requests-current)/float64(previous)
+	wait := time.Duration(needed*float64(limit.Window)) - elapsed
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
+
+func (l *Limiter) increment(ctx context.Context, key string, ttl time.Duration) int64 {
+	if l.cache != nil {
+		count, err := l.cache.Increment(ctx, key, ttl)
+		if err == nil {
+			return count
+		}
+		log.Printf("Error counting %s in the cache, counting in memory: %v", key, err)
+	}
+	count, _ := l.fallback.Increment(ctx, key, ttl)
+	return count
+}
+
+// get reads a value from the shared cache, or from memory when it isn't there
+func (l *Limiter) get(ctx context.Context, key string, dest any) bool {
+	if l.cache != nil && l.cache.Get(ctx, key, dest) == nil {
+		return true
+	}
+	return l.fallback.Get(ctx, key, dest) == nil
+}
+
+func (l *Limiter) set(ctx context.Context, key string, value any, ttl time.Duration) {
+	if l.cache != nil {
+		err := l.cache.Set(ctx, key, value, ttl)
+		if err == nil {
+			return
+		}
+		log.Printf("Error storing %s in the cache, storing it in memory: %v", key, err)
+	}
+	l.fallback.Set(ctx, key, value, ttl)
+}
+
+func (l *Limiter) delete(ctx context.Context, key string) {
+	if l.cache != nil {
+		l.cache.Delete(ctx, key)
+	}
+	l.fallback.Delete(ctx, key)
+}
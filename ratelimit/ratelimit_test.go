@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testLimiter returns a limiter counting in memory whose clock is set by the test
+func testLimiter(now *time.Time) *Limiter {
+	limiter := NewLimiter(nil)
+	limiter.now = func() time.Time { return *now }
+	return limiter
+}
+
+func TestAllowWithinCurrentWindow(t *testing.T) {
+	limit := Limit{Requests: 10, Window: time.Minute}
+	now := time.Unix(0, 0).Add(100 * time.Minute).Add(30 * time.Second)
+	limiter := testLimiter(&now)
+	ctx := context.Background()
+
+	for i := 1; i <= 10; i++ {
+		result := limiter.Allow(ctx, "key", limit)
+		if !result.Allowed {
+			t.Fatalf("request %d was rejected", i)
+		}
+		if result.Remaining != 10-i {
+			t.Errorf("request %d: remaining = %d, want %d", i, result.Remaining, 10-i)
+		}
+	}
+
+	result := limiter.Allow(ctx, "key", limit)
+	if result.Allowed {
+		t.Fatal("request 11 was allowed")
+	}
+	if result.RetryAfter != 30*time.Second {
+		t.Errorf("retry after = %v, want 30s", result.RetryAfter)
+	}
+
+	if result := limiter.Allow(ctx, "other", limit); !result.Allowed {
+		t.Error("a different key was rejected")
+	}
+}
+
+func TestAllowWeighsPreviousWindow(t *testing.T) {
+	limit := Limit{Requests: 10, Window: time.Minute}
+	now := time.Unix(0, 0).Add(100 * time.Minute)
+	limiter := testLimiter(&now)
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		limiter.Allow(ctx, "key", limit)
+	}
+
+	// Halfway through the next window the previous one still counts for 5
+	now = now.Add(90 * time.Second)
+	for i := 1; i <= 5; i++ {
+		if result := limiter.Allow(ctx, "key", limit); !result.Allowed {
+			t.Fatalf("request %d was rejected", i)
+		}
+	}
+	result := limiter.Allow(ctx, "key", limit)
+	if result.Allowed {
+		t.Fatal("request over the sliding limit was allowed")
+	}
+	if result.RetryAfter != 6*time.Second {
+		t.Errorf("retry after = %v, want 6s", result.RetryAfter)
+	}
+}
+
+func TestAllowZeroLimit(t *testing.T) {
+	now := time.Now()
+	limiter := testLimiter(&now)
+
+	for i := 0; i < 100; i++ {
+		if result := limiter.Allow(context.Background(), "key", Limit{}); !result.Allowed {
+			t.Fatal("the zero limit rejected a request")
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	limit := Limit{Requests: 10, Window: time.Minute}
+	tests := []struct {
+		name              string
+		elapsed           time.Duration
+		previous, current int64
+		want              time.Duration
+	}{
+		{"no previous window", 20 * time.Second, 0, 11, 40 * time.Second},
+		{"current window over the limit", 20 * time.Second, 10, 11, 40 * time.Second},
+		{"previous window decaying", 30 * time.Second, 10, 6, 6 * time.Second},
+		{"at least a second", 30 * time.Second, 2, 9, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(limit, tt.elapsed, tt.previous, tt.current); got != tt.want {
+				t.Errorf("retryAfter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("20/1m")
+	if err != nil || limit != (Limit{Requests: 20, Window: time.Minute}) {
+		t.Errorf("ParseLimit(20/1m) = %v, %v", limit, err)
+	}
+
+	for _, value := range []string{"20", "x/1m", "-1/1m", "20/0s", "20/x"} {
+		if _, err := ParseLimit(value); err == nil {
+			t.Errorf("ParseLimit(%q) didn't fail", value)
+		}
+	}
+}
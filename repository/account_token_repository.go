@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrAccountTokenNotFound is returned for account tokens that were never issued,
+// were already used or have expired
+var ErrAccountTokenNotFound = errors.New("account token not found")
+
+// AccountTokenRepository stores the email verification and password reset tokens
+type AccountTokenRepository struct {
+	Mongo *db.MongoRepo
+}
+
+func NewAccountTokenRepository(mongo *db.MongoRepo) *AccountTokenRepository {
+	return &AccountTokenRepository{Mongo: mongo}
+}
+
+// Create stores a token, discarding the unused tokens the user had for the same
+// purpose so only the latest emailed link works
+func (r *AccountTokenRepository) Create(token *models.AccountToken) error {
+	if err := r.DeleteForUser(token.UserID, token.Purpose); err != nil {
+		return err
+	}
+
+	result, err := r.Mongo.Create("account_tokens", token)
+	if err != nil {
+		return err
+	}
+	token.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// Consume marks a token as used and returns it. Each token can be consumed once.
+func (r *AccountTokenRepository) Consume(purpose models.TokenPurpose, tokenHash string) (*models.AccountToken, error) {
+	now := time.Now()
+	filter := bson.M{
+		"purpose":    purpose,
+		"token_hash": tokenHash,
+		"used_at":    bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": now},
+	}
+	matched, err := r.Mongo.UpdateOneMatched("account_tokens", filter, bson.M{"$set": bson.M{"used_at": now}})
+	if err != nil {
+		return nil, err
+	}
+	if matched == 0 {
+		return nil, ErrAccountTokenNotFound
+	}
+
+	var token models.AccountToken
+	if _, err := r.Mongo.FindOne("account_tokens", bson.M{"purpose": purpose, "token_hash": tokenHash}, &token); err != nil {
+		return nil, ErrAccountTokenNotFound
+	}
+	return &token, nil
+}
+
+// DeleteForUser discards the unused tokens of a user for a purpose
+func (r *AccountTokenRepository) DeleteForUser(userID primitive.ObjectID, purpose models.TokenPurpose) error {
+	_, err := r.Mongo.DeleteMany("account_tokens", bson.M{"user_id": userID, "purpose": purpose, "used_at": bson.M{"$exists": false}})
+	return err
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrAPIKeyNotFound is returned for API keys that don't exist or were revoked
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// apiKeyUsageInterval is how often the last use of an API key is recorded, so
+// scripts making many requests don't write on each one
+const apiKeyUsageInterval = time.Minute
+
+type APIKeyRepository struct {
+	Mongo *db.MongoRepo
+}
+
+func NewAPIKeyRepository(mongo *db.MongoRepo) *APIKeyRepository {
+	return &APIKeyRepository{Mongo: mongo}
+}
+
+func (r *APIKeyRepository) Create(key *models.APIKey) error {
+	result, err := r.Mongo.Create("api_keys", key)
+	if err != nil {
+		return err
+	}
+	key.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// GetByUser lists the active API keys of a user
+func (r *APIKeyRepository) GetByUser(userID primitive.ObjectID) ([]models.APIKey, error) {
+	filter := bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	keys := []models.APIKey{}
+	if err := r.Mongo.FindAllWithOptions("api_keys", filter, &keys, opts); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Revoke revokes an active API key of a user
+func (r *APIKeyRepository) Revoke(userID, id primitive.ObjectID) error {
+	filter := bson.M{"_id": id, "user_id": userID, "revoked_at": bson.M{"$exists": false}}
+	matched, err := r.Mongo.UpdateOneMatched("api_keys", filter, bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// Authenticate returns an active API key and its user, recording its use
+func (r *APIKeyRepository) Authenticate(key string) (*models.APIKey, *models.User, error) {
+	var apiKey models.APIKey
+	filter := bson.M{"key_hash": models.HashAPIKey(key), "revoked_at": bson.M{"$exists": false}}
+	if _, err := r.Mongo.FindOne("api_keys", filter, &apiKey); err != nil {
+		return nil, nil, ErrAPIKeyNotFound
+	}
+
+	var user models.User
+	if _, err := r.Mongo.FindOne("users", bson.M{"_id": apiKey.UserID}, &user); err != nil {
+		return nil, nil, ErrAPIKeyNotFound
+	}
+
+	now := time.Now()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyUsageInterval {
+		if err := r.Mongo.UpdateOne("api_keys", bson.M{"_id": apiKey.ID}, bson.M{"$set": bson.M{"last_used_at": now}}); err == nil {
+			apiKey.LastUsedAt = &now
+		}
+	}
+	return &apiKey, &user, nil
+}
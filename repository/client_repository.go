@@ -2,7 +2,9 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
 	"time"
 
 	"github/Rubncal04/youtube-premium/cache"
@@ -11,8 +13,40 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrVersionConflict is returned when a client was modified after the version the
+// caller based its changes on
+var ErrVersionConflict = errors.New("client was modified by another request")
+
+// ErrStatusConflict is returned when a client's status was changed by another
+// request while a status change was being made
+var ErrStatusConflict = errors.New("client status was changed by another request")
+
+// ErrClientPurging is returned when restoring a client the purge job is already
+// removing
+var ErrClientPurging = errors.New("client is being purged")
+
+// ClientFilter holds the optional filters of the client listing
+type ClientFilter struct {
+	Status   models.ClientStatus
+	DayFrom  int        // Minimum day to pay, 0 for no minimum
+	DayTo    int        // Maximum day to pay, 0 for no maximum
+	PaidFrom *time.Time // Last payment date range
+	PaidTo   *time.Time
+	Search   string // Case insensitive search on the name
+}
+
+// ClientSortFields maps the sort parameters of the client listing to document fields
+var ClientSortFields = map[string]string{
+	"name":              "name",
+	"day_to_pay":        "day_to_pay",
+	"last_payment_date": "last_payment_date",
+	"created_at":        "created_at",
+}
+
 type ClientRepository struct {
 	Mongo *db.MongoRepo
 	Cache *cache.RedisCache
@@ -32,16 +66,48 @@ func (r *ClientRepository) Create(client models.Client) (models.Client, error) {
 		return models.Client{}, err
 	}
 
-	// Invalidar caché de lista de clientes
+	// Invalidar caché de lista de clientes y estadísticas
 	if r.Cache != nil {
 		cacheKey := cache.GenerateKey("clients", client.UserID.Hex())
 		r.Cache.Delete(context.Background(), cacheKey)
+		InvalidateStats(context.Background(), r.Cache, client.UserID)
 	}
 
 	client.ID = result.InsertedID.(primitive.ObjectID)
 	return client, nil
 }
 
+// CreateMany creates several clients of a user in a single transaction, so
+// either all of them are created or none is
+func (r *ClientRepository) CreateMany(userID primitive.ObjectID, clients []models.Client) ([]models.Client, error) {
+	docs := make([]any, len(clients))
+	for i, client := range clients {
+		docs[i] = client
+	}
+
+	err := r.Mongo.WithTransaction(func(ctx mongo.SessionContext) error {
+		ids, err := r.Mongo.InsertMany(ctx, "clients", docs)
+		if err != nil {
+			return err
+		}
+		for i, id := range ids {
+			clients[i].ID = id.(primitive.ObjectID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Invalidar caché de lista de clientes y estadísticas
+	if r.Cache != nil {
+		r.Cache.Delete(context.Background(), cache.GenerateKey("clients", userID.Hex()))
+		InvalidateStats(context.Background(), r.Cache, userID)
+	}
+
+	return clients, nil
+}
+
 func (r *ClientRepository) GetByID(id string) (*models.Client, error) {
 	ctx := context.Background()
 	cacheKey := cache.GenerateKey("client", id)
@@ -61,7 +127,7 @@ func (r *ClientRepository) GetByID(id string) (*models.Client, error) {
 		return nil, fmt.Errorf("invalid client ID: %v", err)
 	}
 
-	filter := bson.M{"_id": objID}
+	filter := NotDeleted(bson.M{"_id": objID})
 	var client models.Client
 	_, err = r.Mongo.FindOne("clients", filter, &client)
 	if err != nil {
@@ -80,28 +146,64 @@ func (r *ClientRepository) GetByID(id string) (*models.Client, error) {
 	return &client, nil
 }
 
-func (r *ClientRepository) Update(id string, updateData bson.M) error {
-	objID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return fmt.Errorf("invalid client ID: %v", err)
+func (r *ClientRepository) Update(client *models.Client, updateData bson.M) error {
+	filter := bson.M{"_id": client.ID}
+	update := bson.M{
+		"$set": updateData,
+		"$inc": bson.M{"version": 1},
+	}
+
+	if err := r.Mongo.UpdateOne("clients", filter, update); err != nil {
+		return err
+	}
+
+	r.invalidate(client)
+	return nil
+}
+
+// UpdateMany sets the same fields on several clients at once
+func (r *ClientRepository) UpdateMany(clients []models.Client, updateData bson.M) error {
+	ids := make([]primitive.ObjectID, len(clients))
+	for i, client := range clients {
+		ids[i] = client.ID
+	}
+
+	filter := bson.M{"_id": bson.M{"$in": ids}}
+	update := bson.M{"$set": updateData, "$inc": bson.M{"version": 1}}
+	if _, err := r.Mongo.UpdateMany("clients", filter, update); err != nil {
+		return err
+	}
+
+	for i := range clients {
+		r.invalidate(&clients[i])
 	}
+	return nil
+}
+
+// AnyVersion makes Patch apply its changes whatever the client's current version is
+const AnyVersion int64 = -1
 
-	filter := bson.M{"_id": objID}
+// Patch sets the given fields only if the client is still at the expected
+// version, returning ErrVersionConflict when it was changed in the meantime
+func (r *ClientRepository) Patch(client *models.Client, updateData bson.M, version int64) error {
+	filter := bson.M{"_id": client.ID, "version": version}
+	if version == AnyVersion {
+		delete(filter, "version")
+	}
 	update := bson.M{
 		"$set": updateData,
+		"$inc": bson.M{"version": 1},
 	}
 
-	err = r.Mongo.UpdateOne("clients", filter, update)
+	matched, err := r.Mongo.UpdateOneMatched("clients", filter, update)
 	if err != nil {
 		return err
 	}
-
-	// Invalidar caché
-	if r.Cache != nil {
-		cacheKey := cache.GenerateKey("client", id)
-		r.Cache.Delete(context.Background(), cacheKey)
+	if matched == 0 {
+		return ErrVersionConflict
 	}
 
+	r.invalidate(client)
 	return nil
 }
 
@@ -119,7 +221,7 @@ func (r *ClientRepository) GetAll(userID primitive.ObjectID) ([]models.Client, e
 	}
 
 	// Si no está en caché, obtener de MongoDB
-	filter := bson.M{"user_id": userID}
+	filter := NotDeleted(bson.M{"user_id": userID})
 	var clients []models.Client
 	err := r.Mongo.FindAll("clients", filter, &clients)
 	if err != nil {
@@ -137,6 +239,53 @@ func (r *ClientRepository) GetAll(userID primitive.ObjectID) ([]models.Client, e
 	return clients, nil
 }
 
+// List returns a page of the user's clients matching the filter
+func (r *ClientRepository) List(userID primitive.ObjectID, filter ClientFilter, query ListQuery) (Page[models.Client], error) {
+	return findPage[models.Client](r.Mongo, "clients", clientConditions(userID, filter), query)
+}
+
+// Each streams every client of the user matching the filter, in the query's order
+func (r *ClientRepository) Each(userID primitive.ObjectID, filter ClientFilter, query ListQuery, fn func(models.Client) error) error {
+	return eachDocument(r.Mongo, "clients", clientConditions(userID, filter), query, fn)
+}
+
+// clientConditions builds the Mongo filter of the client listing
+func clientConditions(userID primitive.ObjectID, filter ClientFilter) bson.M {
+	conditions := NotDeleted(bson.M{"user_id": userID})
+
+	if filter.Status != "" {
+		conditions["status"] = filter.Status
+	}
+
+	day := bson.M{}
+	if filter.DayFrom > 0 {
+		day["$gte"] = filter.DayFrom
+	}
+	if filter.DayTo > 0 {
+		day["$lte"] = filter.DayTo
+	}
+	if len(day) > 0 {
+		conditions["day_to_pay"] = day
+	}
+
+	paid := bson.M{}
+	if filter.PaidFrom != nil {
+		paid["$gte"] = *filter.PaidFrom
+	}
+	if filter.PaidTo != nil {
+		paid["$lte"] = *filter.PaidTo
+	}
+	if len(paid) > 0 {
+		conditions["last_payment_date"] = paid
+	}
+
+	if filter.Search != "" {
+		conditions["name"] = bson.M{"$regex": regexp.QuoteMeta(filter.Search), "$options": "i"}
+	}
+
+	return conditions
+}
+
 func (r *ClientRepository) UpdateLastPaymentDate(clientID primitive.ObjectID, lastPaymentDate primitive.DateTime) error {
 	filter := bson.M{"_id": clientID}
 	update := bson.M{
@@ -160,12 +309,254 @@ func (r *ClientRepository) UpdateLastPaymentDate(clientID primitive.ObjectID, la
 	return nil
 }
 
-func (r *ClientRepository) Delete(id string) error {
+// UpdatePaidThrough sets the end of the last billing period the client has paid for
+func (r *ClientRepository) UpdatePaidThrough(clientID primitive.ObjectID, paidThrough time.Time) error {
+	filter := bson.M{"_id": clientID}
+	update := bson.M{
+		"$set": bson.M{
+			"paid_through": paidThrough,
+			"updated_at":   time.Now(),
+		},
+	}
+
+	err := r.Mongo.UpdateOne("clients", filter, update)
+	if err != nil {
+		return err
+	}
+
+	// Invalidar caché
+	if r.Cache != nil {
+		cacheKey := cache.GenerateKey("client", clientID.Hex())
+		r.Cache.Delete(context.Background(), cacheKey)
+	}
+
+	return nil
+}
+
+// ChangeStatus moves the client to a new status, validating the transition and
+// recording it in the client's status history. The history entry is written
+// first and removed again if the client changed status in the meantime, so a
+// status is never changed without its history.
+func (r *ClientRepository) ChangeStatus(client *models.Client, newStatus models.ClientStatus, reason string, changedBy *primitive.ObjectID) error {
+	if err := client.ValidateStateTransition(newStatus); err != nil {
+		return err
+	}
+
+	change := models.NewClientStatusChange(client.ID, client.Status, newStatus, reason, changedBy)
+	result, err := r.Mongo.Create("client_status_history", change)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": client.ID, "status": client.Status}
+	update := bson.M{
+		"$set": bson.M{"status": newStatus, "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+
+	matched, err := r.Mongo.UpdateOneMatched("clients", filter, update)
+	if err == nil && matched == 0 {
+		err = ErrStatusConflict
+	}
+	if err != nil {
+		if deleteErr := r.Mongo.DeleteOne("client_status_history", bson.M{"_id": result.InsertedID}); deleteErr != nil {
+			return fmt.Errorf("%w, and its history entry was not removed: %v", err, deleteErr)
+		}
+		return err
+	}
+
+	r.invalidate(client)
+	client.Status = newStatus
+	client.Version++
+	return nil
+}
+
+// ChangeStatusInTransaction works like ChangeStatus inside a transaction, which
+// discards the history entry by itself when the status changed in the meantime.
+// The caller invalidates the client's cache once the transaction commits.
+func (r *ClientRepository) ChangeStatusInTransaction(ctx mongo.SessionContext, client *models.Client, newStatus models.ClientStatus, reason string, changedBy *primitive.ObjectID) error {
+	if err := client.ValidateStateTransition(newStatus); err != nil {
+		return err
+	}
+
+	change := models.NewClientStatusChange(client.ID, client.Status, newStatus, reason, changedBy)
+	if _, err := r.Mongo.InsertMany(ctx, "client_status_history", []any{change}); err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": client.ID, "status": client.Status}
+	update := bson.M{
+		"$set": bson.M{"status": newStatus, "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+	matched, err := r.Mongo.UpdateOneMatchedWithContext(ctx, "clients", filter, update)
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return ErrStatusConflict
+	}
+
+	client.Status = newStatus
+	client.Version++
+	return nil
+}
+
+// SyncStatusWithBalance keeps the status of a billable client in line with its balance.
+// Suspended, cancelled and archived clients are left untouched.
+func (r *ClientRepository) SyncStatusWithBalance(client *models.Client, balance float64) error {
+	status, changed := client.StatusForBalance(balance)
+	if !changed {
+		return nil
+	}
+
+	return r.ChangeStatus(client, status, "balance updated", nil)
+}
+
+// GetStatusHistory returns the status changes of a client, newest first
+func (r *ClientRepository) GetStatusHistory(clientID primitive.ObjectID) ([]models.ClientStatusChange, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	history := []models.ClientStatusChange{}
+	if err := r.Mongo.FindAllWithOptions("client_status_history", bson.M{"client_id": clientID}, &history, opts); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// Delete moves the client to the trash. Its payments and ledger are kept until
+// the client is purged, so it can be restored.
+func (r *ClientRepository) Delete(client *models.Client) error {
+	now := time.Now()
+	filter := bson.M{"_id": client.ID}
+	update := bson.M{
+		"$set": bson.M{
+			"deleted_at": now,
+			"updated_at": now,
+		},
+	}
+
+	if err := r.Mongo.UpdateOne("clients", filter, update); err != nil {
+		return err
+	}
+
+	r.invalidate(client)
+	return nil
+}
+
+// Restore takes a client out of the trash. It returns ErrClientPurging when the
+// purge job already started removing the client.
+func (r *ClientRepository) Restore(client *models.Client) error {
+	filter := bson.M{"_id": client.ID, "purging": bson.M{"$ne": true}}
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": ""},
+		"$set":   bson.M{"updated_at": time.Now()},
+	}
+
+	matched, err := r.Mongo.UpdateOneMatched("clients", filter, update)
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return ErrClientPurging
+	}
+
+	r.invalidate(client)
+	client.DeletedAt = nil
+	return nil
+}
+
+// GetDeletedByID returns a client that is in the trash and not being purged
+func (r *ClientRepository) GetDeletedByID(id string) (*models.Client, error) {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return fmt.Errorf("invalid client ID: %v", err)
+		return nil, fmt.Errorf("invalid client ID: %v", err)
+	}
+
+	filter := bson.M{"_id": objID, "deleted_at": bson.M{"$exists": true}, "purging": bson.M{"$ne": true}}
+	var client models.Client
+	if _, err := r.Mongo.FindOne("clients", filter, &client); err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// GetDeleted returns the clients of a user that are in the trash, most recently
+// deleted first. Clients being purged are no longer listed.
+func (r *ClientRepository) GetDeleted(userID primitive.ObjectID) ([]models.Client, error) {
+	filter := bson.M{"user_id": userID, "deleted_at": bson.M{"$exists": true}, "purging": bson.M{"$ne": true}}
+	opts := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+
+	clients := []models.Client{}
+	if err := r.Mongo.FindAllWithOptions("clients", filter, &clients, opts); err != nil {
+		return nil, err
 	}
+	return clients, nil
+}
+
+// GetDeletedIDs returns the IDs of all the clients of a user that are in the
+// trash, including the ones being purged, so their data can be left out of
+// listings
+func (r *ClientRepository) GetDeletedIDs(userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	filter := bson.M{"user_id": userID, "deleted_at": bson.M{"$exists": true}}
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+
+	var clients []models.Client
+	if err := r.Mongo.FindAllWithOptions("clients", filter, &clients, opts); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, len(clients))
+	for i, client := range clients {
+		ids[i] = client.ID
+	}
+	return ids, nil
+}
+
+// Purge permanently removes a deleted client together with its payments, ledger,
+// status history and discounts. The client is marked as purging first, so it
+// can't be restored once its data starts being removed, and is deleted last, so
+// a purge that fails halfway is completed when it is retried.
+func (r *ClientRepository) Purge(client models.Client) error {
+	filter := bson.M{"_id": client.ID, "deleted_at": bson.M{"$exists": true}}
+	matched, err := r.Mongo.UpdateOneMatched("clients", filter, bson.M{"$set": bson.M{"purging": true}})
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return fmt.Errorf("client %s is not in the trash", client.ID.Hex())
+	}
+	r.invalidate(&client)
+
+	dependents := []string{"payments", "ledger_entries", "client_status_history", "discounts"}
+	for _, collection := range dependents {
+		if _, err := r.Mongo.DeleteMany(collection, bson.M{"client_id": client.ID}); err != nil {
+			return fmt.Errorf("error purging %s: %v", collection, err)
+		}
+	}
+
+	if err := r.Mongo.DeleteOne("ledger_balances", bson.M{"_id": client.ID}); err != nil {
+		return fmt.Errorf("error purging ledger_balances: %v", err)
+	}
+
+	return r.Mongo.DeleteOne("clients", bson.M{"_id": client.ID})
+}
+
+// invalidate removes the cached client, the cached client list of its owner and
+// the owner's cached stats, which count clients by status
+func (r *ClientRepository) invalidate(client *models.Client) {
+	if r.Cache == nil {
+		return
+	}
+
+	ctx := context.Background()
+	r.Cache.Delete(ctx, cache.GenerateKey("client", client.ID.Hex()))
+	r.Cache.Delete(ctx, cache.GenerateKey("clients", client.UserID.Hex()))
+	InvalidateStats(ctx, r.Cache, client.UserID)
+}
 
-	filter := bson.M{"_id": objID}
-	return r.Mongo.DeleteOne("clients", filter)
+// NotDeleted adds the condition that excludes clients in the trash to a filter
+func NotDeleted(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$exists": false}
+	return filter
 }
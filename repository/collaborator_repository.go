@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvitationNotFound is returned for invitations that don't exist, were
+// already accepted or have expired
+var ErrInvitationNotFound = errors.New("invitation not found")
+
+// CollaboratorRepository stores the invitations owners send and the grants
+// collaborators get when accepting them
+type CollaboratorRepository struct {
+	Mongo *db.MongoRepo
+}
+
+func NewCollaboratorRepository(mongo *db.MongoRepo) *CollaboratorRepository {
+	return &CollaboratorRepository{Mongo: mongo}
+}
+
+func (r *CollaboratorRepository) CreateInvitation(invitation *models.Invitation) error {
+	result, err := r.Mongo.Create("invitations", invitation)
+	if err != nil {
+		return err
+	}
+	invitation.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// GetPendingInvitations lists the invitations of an owner that can still be accepted
+func (r *CollaboratorRepository) GetPendingInvitations(ownerID primitive.ObjectID) ([]models.Invitation, error) {
+	filter := bson.M{
+		"owner_id":    ownerID,
+		"accepted_at": bson.M{"$exists": false},
+		"expires_at":  bson.M{"$gt": time.Now()},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	invitations := []models.Invitation{}
+	if err := r.Mongo.FindAllWithOptions("invitations", filter, &invitations, opts); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// DeleteInvitation revokes a pending invitation of an owner
+func (r *CollaboratorRepository) DeleteInvitation(ownerID, id primitive.ObjectID) error {
+	deleted, err := r.Mongo.DeleteMany("invitations", bson.M{"_id": id, "owner_id": ownerID, "accepted_at": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// GetInvitationByTokenHash finds a pending invitation by the hash of its token
+func (r *CollaboratorRepository) GetInvitationByTokenHash(tokenHash string) (*models.Invitation, error) {
+	filter := bson.M{
+		"token_hash":  tokenHash,
+		"accepted_at": bson.M{"$exists": false},
+		"expires_at":  bson.M{"$gt": time.Now()},
+	}
+
+	var invitation models.Invitation
+	if _, err := r.Mongo.FindOne("invitations", filter, &invitation); err != nil {
+		return nil, ErrInvitationNotFound
+	}
+	return &invitation, nil
+}
+
+// AcceptInvitation marks an invitation as accepted by a collaborator and grants
+// them its scope, replacing the scope of an existing grant from the same owner
+func (r *CollaboratorRepository) AcceptInvitation(invitation *models.Invitation, collaborator *models.User) (*models.Grant, error) {
+	now := time.Now()
+	filter := bson.M{"_id": invitation.ID, "accepted_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"accepted_by": collaborator.ID, "accepted_at": now}}
+	matched, err := r.Mongo.UpdateOneMatched("invitations", filter, update)
+	if err != nil {
+		return nil, err
+	}
+	if matched == 0 {
+		return nil, ErrInvitationNotFound
+	}
+
+	grant, err := r.GetGrant(invitation.OwnerID, collaborator.ID)
+	if err == nil {
+		grant.Scope = invitation.Scope
+		grant.UpdatedAt = now
+		return grant, r.UpdateGrantScope(grant.ID, invitation.Scope)
+	}
+
+	grant = &models.Grant{
+		OwnerID:           invitation.OwnerID,
+		CollaboratorID:    collaborator.ID,
+		CollaboratorEmail: collaborator.Email,
+		Scope:             invitation.Scope,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	result, err := r.Mongo.Create("grants", grant)
+	if err != nil {
+		return nil, err
+	}
+	grant.ID = result.InsertedID.(primitive.ObjectID)
+	return grant, nil
+}
+
+// GetGrant returns the grant an owner gave a collaborator
+func (r *CollaboratorRepository) GetGrant(ownerID, collaboratorID primitive.ObjectID) (*models.Grant, error) {
+	var grant models.Grant
+	_, err := r.Mongo.FindOne("grants", bson.M{"owner_id": ownerID, "collaborator_id": collaboratorID}, &grant)
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// GetGrantByID returns a grant given by an owner
+func (r *CollaboratorRepository) GetGrantByID(ownerID, id primitive.ObjectID) (*models.Grant, error) {
+	var grant models.Grant
+	_, err := r.Mongo.FindOne("grants", bson.M{"_id": id, "owner_id": ownerID}, &grant)
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// GetGrantsByOwner lists the collaborators of an owner
+func (r *CollaboratorRepository) GetGrantsByOwner(ownerID primitive.ObjectID) ([]models.Grant, error) {
+	return r.findGrants(bson.M{"owner_id": ownerID})
+}
+
+// GetGrantsByCollaborator lists the owners that gave a user access
+func (r *CollaboratorRepository) GetGrantsByCollaborator(collaboratorID primitive.ObjectID) ([]models.Grant, error) {
+	return r.findGrants(bson.M{"collaborator_id": collaboratorID})
+}
+
+func (r *CollaboratorRepository) findGrants(filter bson.M) ([]models.Grant, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	grants := []models.Grant{}
+	if err := r.Mongo.FindAllWithOptions("grants", filter, &grants, opts); err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+func (r *CollaboratorRepository) UpdateGrantScope(id primitive.ObjectID, scope models.GrantScope) error {
+	update := bson.M{"$set": bson.M{"scope": scope, "updated_at": time.Now()}}
+	return r.Mongo.UpdateOne("grants", bson.M{"_id": id}, update)
+}
+
+func (r *CollaboratorRepository) DeleteGrant(id primitive.ObjectID) error {
+	return r.Mongo.DeleteOne("grants", bson.M{"_id": id})
+}
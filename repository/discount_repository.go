@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DiscountRepository struct {
+	Mongo *db.MongoRepo
+}
+
+func NewDiscountRepository(mongo *db.MongoRepo) *DiscountRepository {
+	return &DiscountRepository{Mongo: mongo}
+}
+
+func (r *DiscountRepository) Create(discount *models.Discount) error {
+	if err := discount.Validate(); err != nil {
+		return err
+	}
+
+	result, err := r.Mongo.Create("discounts", discount)
+	if err != nil {
+		return err
+	}
+
+	discount.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *DiscountRepository) GetByID(id string) (*models.Discount, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid discount ID: %v", err)
+	}
+
+	var discount models.Discount
+	_, err = r.Mongo.FindOne("discounts", bson.M{"_id": objID}, &discount)
+	if err != nil {
+		return nil, err
+	}
+	return &discount, nil
+}
+
+// GetByUserID returns every discount created by an owner, newest first
+func (r *DiscountRepository) GetByUserID(userID primitive.ObjectID) ([]models.Discount, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	discounts := []models.Discount{}
+	err := r.Mongo.FindAllWithOptions("discounts", bson.M{"user_id": userID}, &discounts, opts)
+	if err != nil {
+		return nil, err
+	}
+	return discounts, nil
+}
+
+// GetApplicable returns the active discounts that apply to a client: its own
+// discounts and the ones defined for the owner's whole plan, oldest first. Plan
+// discounts come with the cycles the client used.
+func (r *DiscountRepository) GetApplicable(client models.Client) ([]models.Discount, error) {
+	filter := bson.M{
+		"user_id": client.UserID,
+		"active":  true,
+		"$or": []bson.M{
+			{"client_id": client.ID},
+			{"client_id": bson.M{"$exists": false}},
+		},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	var discounts []models.Discount
+	if err := r.Mongo.FindAllWithOptions("discounts", filter, &discounts, opts); err != nil {
+		return nil, err
+	}
+	for i := range discounts {
+		discounts[i] = discounts[i].ForClient(client.ID)
+	}
+	return discounts, nil
+}
+
+// IncrementUsage records that a discount was applied to one more billing period
+// of a client. Client discounts are deactivated once all their cycles are used,
+// plan discounts count the cycles of each client and stay active for the others.
+func (r *DiscountRepository) IncrementUsage(discount models.Discount, clientID primitive.ObjectID) error {
+	set := bson.M{"updated_at": time.Now()}
+	inc := bson.M{"cycles_used": 1}
+	if discount.ClientID == nil {
+		inc = bson.M{"client_cycles_used." + clientID.Hex(): 1}
+	} else if discount.Cycles > 0 && discount.CyclesUsed+1 >= discount.Cycles {
+		set["active"] = false
+	}
+
+	update := bson.M{
+		"$inc": inc,
+		"$set": set,
+	}
+	return r.Mongo.UpdateOne("discounts", bson.M{"_id": discount.ID}, update)
+}
+
+// Deactivate stops a discount from being applied, recording who did it
+func (r *DiscountRepository) Deactivate(id primitive.ObjectID, deactivatedBy primitive.ObjectID) error {
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"active":         false,
+			"deactivated_by": deactivatedBy,
+			"deactivated_at": now,
+			"updated_at":     now,
+		},
+	}
+	return r.Mongo.UpdateOne("discounts", bson.M{"_id": id}, update)
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"time"
+
+	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type LateFeePolicyRepository struct {
+	Mongo *db.MongoRepo
+}
+
+func NewLateFeePolicyRepository(mongo *db.MongoRepo) *LateFeePolicyRepository {
+	return &LateFeePolicyRepository{Mongo: mongo}
+}
+
+func (r *LateFeePolicyRepository) GetByUserID(userID primitive.ObjectID) (*models.LateFeePolicy, error) {
+	var policy models.LateFeePolicy
+	_, err := r.Mongo.FindOne("late_fee_policies", bson.M{"user_id": userID}, &policy)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Save creates the owner's policy or replaces the existing one
+func (r *LateFeePolicyRepository) Save(policy *models.LateFeePolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	existing, _ := r.GetByUserID(policy.UserID)
+	if existing == nil {
+		result, err := r.Mongo.Create("late_fee_policies", policy)
+		if err != nil {
+			return err
+		}
+		policy.ID = result.InsertedID.(primitive.ObjectID)
+		return nil
+	}
+
+	policy.ID = existing.ID
+	policy.CreatedAt = existing.CreatedAt
+	policy.UpdatedAt = time.Now()
+
+	update := bson.M{
+		"$set": bson.M{
+			"enabled":        policy.Enabled,
+			"type":           policy.Type,
+			"value":          policy.Value,
+			"after_days":     policy.AfterDays,
+			"cap_per_period": policy.CapPerPeriod,
+			"updated_at":     policy.UpdatedAt,
+		},
+	}
+	return r.Mongo.UpdateOne("late_fee_policies", bson.M{"_id": existing.ID}, update)
+}
+
+func (r *LateFeePolicyRepository) Delete(userID primitive.ObjectID) error {
+	return r.Mongo.DeleteOne("late_fee_policies", bson.M{"user_id": userID})
+}
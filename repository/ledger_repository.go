@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LedgerRepository struct {
+	Mongo *db.MongoRepo
+}
+
+// ledgerBalance holds the current balance of a client. Entries update it with
+// $inc, so concurrent entries can't compute their running balance from the
+// same previous balance and lose one another.
+type ledgerBalance struct {
+	ClientID primitive.ObjectID `bson:"_id"`
+	UserID   primitive.ObjectID `bson:"user_id"`
+	Balance  float64            `bson:"balance"`
+	Sequence int64              `bson:"sequence"` // Number of entries, the sequence of the latest one
+}
+
+func NewLedgerRepository(mongo *db.MongoRepo) *LedgerRepository {
+	return &LedgerRepository{Mongo: mongo}
+}
+
+// AddEntry validates the entry, moves the client's balance and stores the entry
+// with the resulting running balance and its sequence
+func (r *LedgerRepository) AddEntry(entry *models.LedgerEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	var balance ledgerBalance
+	if err := r.Mongo.FindOneAndUpdate("ledger_balances", bson.M{"_id": entry.ClientID}, balanceUpdate(entry), &balance, balanceUpdateOptions()); err != nil {
+		return err
+	}
+	entry.Balance = balance.Balance
+	entry.Sequence = balance.Sequence
+
+	result, err := r.Mongo.Create("ledger_entries", entry)
+	if err != nil {
+		// Give the amount back so the balance matches the stored entries
+		revert := bson.M{"$inc": bson.M{"balance": -entry.SignedAmount()}}
+		if revertErr := r.Mongo.UpdateOne("ledger_balances", bson.M{"_id": entry.ClientID}, revert); revertErr != nil {
+			return fmt.Errorf("%w, and reverting the balance failed: %v", err, revertErr)
+		}
+		return err
+	}
+
+	entry.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// AddEntryInTransaction works like AddEntry inside a transaction, which undoes
+// the balance update by itself when storing the entry fails
+func (r *LedgerRepository) AddEntryInTransaction(ctx mongo.SessionContext, entry *models.LedgerEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	var balance ledgerBalance
+	if err := r.Mongo.FindOneAndUpdateWithContext(ctx, "ledger_balances", bson.M{"_id": entry.ClientID}, balanceUpdate(entry), &balance, balanceUpdateOptions()); err != nil {
+		return err
+	}
+	entry.Balance = balance.Balance
+	entry.Sequence = balance.Sequence
+
+	ids, err := r.Mongo.InsertMany(ctx, "ledger_entries", []any{entry})
+	if err != nil {
+		return err
+	}
+
+	entry.ID = ids[0].(primitive.ObjectID)
+	return nil
+}
+
+// balanceUpdate moves a client's balance by the amount of an entry and counts it,
+// creating the balance of the client's first entry
+func balanceUpdate(entry *models.LedgerEntry) bson.M {
+	return bson.M{
+		"$inc":         bson.M{"balance": entry.SignedAmount(), "sequence": 1},
+		"$setOnInsert": bson.M{"user_id": entry.UserID},
+	}
+}
+
+func balanceUpdateOptions() *options.FindOneAndUpdateOptions {
+	return options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+}
+
+// GetBalance returns the current balance of a client. A client without entries
+// has a balance of 0.
+func (r *LedgerRepository) GetBalance(clientID primitive.ObjectID) (float64, error) {
+	var balance ledgerBalance
+	if _, err := r.Mongo.FindOne("ledger_balances", bson.M{"_id": clientID}, &balance); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return balance.Balance, nil
+}
+
+// GetEntries returns a page of the entries of a client, newest first
+func (r *LedgerRepository) GetEntries(clientID primitive.ObjectID, query ListQuery) (Page[models.LedgerEntry], error) {
+	query.SortField = "sequence"
+	query.Descending = true
+	return findPage[models.LedgerEntry](r.Mongo, "ledger_entries", bson.M{"client_id": clientID}, query)
+}
+
+// EachEntry streams every entry of a client, oldest first
+func (r *LedgerRepository) EachEntry(clientID primitive.ObjectID, fn func(models.LedgerEntry) error) error {
+	query := ListQuery{SortField: "sequence"}
+	return eachDocument(r.Mongo, "ledger_entries", bson.M{"client_id": clientID}, query, fn)
+}
+
+// HasCharge reports whether a charge was already posted for the client in the given period
+func (r *LedgerRepository) HasCharge(clientID primitive.ObjectID, period string) (bool, error) {
+	filter := bson.M{
+		"client_id": clientID,
+		"kind":      models.LedgerKindCharge,
+		"period":    period,
+	}
+
+	count, err := r.Mongo.CountDocuments("ledger_entries", filter)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RecordCharge posts the amount due for a billing period. Posting the same period
+// twice is a no-op and returns nil without error.
+func (r *LedgerRepository) RecordCharge(client models.Client, period string, amount float64) (*models.LedgerEntry, error) {
+	exists, err := r.HasCharge(client.ID, period)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, nil
+	}
+
+	entry := newChargeEntry(client, period, amount)
+	if err := r.AddEntry(entry); err != nil {
+		// Charged in the meantime by another request, see the unique index on charges
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+// RecordPayment credits a completed payment to the client's balance. Any amount
+// above what is owed stays as credit and is applied to the next charges.
+func (r *LedgerRepository) RecordPayment(client models.Client, payment *models.Payment) (*models.LedgerEntry, error) {
+	entry := newPaymentEntry(client, payment)
+	if err := r.AddEntry(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// RecordImportedPayment posts a historical payment inside the transaction that
+// imports it: the payment's credit and the charges of the periods it covers
+// that aren't charged yet, each one at periodAmount. It returns the client's
+// resulting balance.
+func (r *LedgerRepository) RecordImportedPayment(ctx mongo.SessionContext, client models.Client, payment *models.Payment, periodAmount float64) (float64, error) {
+	entry := newPaymentEntry(client, payment)
+	if err := r.AddEntryInTransaction(ctx, entry); err != nil {
+		return 0, err
+	}
+	balance := entry.Balance
+
+	for _, period := range payment.CoveredPeriods() {
+		// Read inside the transaction, so earlier payments of the import count
+		count, err := r.Mongo.CountDocumentsWithContext(ctx, "ledger_entries", bson.M{
+			"client_id": client.ID,
+			"kind":      models.LedgerKindCharge,
+			"period":    period,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if count > 0 {
+			continue
+		}
+
+		charge := newChargeEntry(client, period, periodAmount)
+		if err := r.AddEntryInTransaction(ctx, charge); err != nil {
+			return 0, err
+		}
+		balance = charge.Balance
+	}
+	return balance, nil
+}
+
+func newChargeEntry(client models.Client, period string, amount float64) *models.LedgerEntry {
+	entry := models.NewLedgerEntry(client.ID, client.UserID, models.LedgerEntryDebit, models.LedgerKindCharge, amount,
+		fmt.Sprintf("Charge for period %s", period))
+	entry.Period = period
+	return entry
+}
+
+func newPaymentEntry(client models.Client, payment *models.Payment) *models.LedgerEntry {
+	entry := models.NewLedgerEntry(client.ID, client.UserID, models.LedgerEntryCredit, models.LedgerKindPayment, payment.Amount,
+		"Payment received")
+	entry.ReferenceID = &payment.ID
+	return entry
+}
+
+// RecordDiscount credits the reduction a discount produced on a period's charge
+func (r *LedgerRepository) RecordDiscount(client models.Client, period string, applied models.AppliedDiscount) (*models.LedgerEntry, error) {
+	entry := models.NewLedgerEntry(client.ID, client.UserID, models.LedgerEntryCredit, models.LedgerKindDiscount, applied.Amount,
+		fmt.Sprintf("Discount for period %s: %s", period, applied.Description))
+	entry.Period = period
+	entry.ReferenceID = &applied.DiscountID
+
+	if err := r.AddEntry(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// GetEntryByID returns a single ledger entry
+func (r *LedgerRepository) GetEntryByID(id string) (*models.LedgerEntry, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ledger entry ID: %v", err)
+	}
+
+	var entry models.LedgerEntry
+	_, err = r.Mongo.FindOne("ledger_entries", bson.M{"_id": objID}, &entry)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetPeriodEntries returns the entries of a kind posted for a client in a billing period, oldest first
+func (r *LedgerRepository) GetPeriodEntries(clientID primitive.ObjectID, period string, kind models.LedgerEntryKind) ([]models.LedgerEntry, error) {
+	filter := bson.M{
+		"client_id": clientID,
+		"period":    period,
+		"kind":      kind,
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	var entries []models.LedgerEntry
+	if err := r.Mongo.FindAllWithOptions("ledger_entries", filter, &entries, opts); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetLateFeesTotal returns the late fees charged to a client in a period, net of reversals
+func (r *LedgerRepository) GetLateFeesTotal(clientID primitive.ObjectID, period string) (float64, error) {
+	fees, err := r.GetPeriodEntries(clientID, period, models.LedgerKindLateFee)
+	if err != nil {
+		return 0, err
+	}
+	reversals, err := r.GetPeriodEntries(clientID, period, models.LedgerKindFeeReverse)
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, fee := range fees {
+		total += fee.Amount
+	}
+	for _, reversal := range reversals {
+		total -= reversal.Amount
+	}
+	return total, nil
+}
+
+// RecordLateFee charges a late fee to the client for a billing period
+func (r *LedgerRepository) RecordLateFee(client models.Client, period string, amount float64) (*models.LedgerEntry, error) {
+	entry := models.NewLedgerEntry(client.ID, client.UserID, models.LedgerEntryDebit, models.LedgerKindLateFee, amount,
+		fmt.Sprintf("Late fee for period %s", period))
+	entry.Period = period
+
+	if err := r.AddEntry(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ReverseLateFee gives a late fee back to the client. A fee can only be reversed once.
+func (r *LedgerRepository) ReverseLateFee(fee *models.LedgerEntry, reversedBy primitive.ObjectID) (*models.LedgerEntry, error) {
+	if fee.Kind != models.LedgerKindLateFee {
+		return nil, errors.New("only late fees can be reversed")
+	}
+
+	count, err := r.Mongo.CountDocuments("ledger_entries", bson.M{
+		"kind":         models.LedgerKindFeeReverse,
+		"reference_id": fee.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("late fee already reversed")
+	}
+
+	entry := models.NewLedgerEntry(fee.ClientID, fee.UserID, models.LedgerEntryCredit, models.LedgerKindFeeReverse, fee.Amount,
+		fmt.Sprintf("Reversal of late fee for period %s", fee.Period))
+	entry.Period = fee.Period
+	entry.ReferenceID = &fee.ID
+	entry.CreatedBy = reversedBy
+
+	if err := r.AddEntry(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
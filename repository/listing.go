@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github/Rubncal04/youtube-premium/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListQuery holds the sorting and cursor pagination options of a listing
+type ListQuery struct {
+	SortField  string // Document field to sort by, ties are broken by _id
+	Descending bool
+	Limit      int
+	Cursor     string // Opaque cursor returned as NextCursor by the previous page
+}
+
+// Page is a page of results of a cursor paginated listing
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"` // Empty on the last page
+	Total      int64  `json:"total"`                 // Total number of documents matching the filters
+}
+
+// pageCursor is the position of the last item of a page, with the sort it was
+// taken from so it isn't used with a different one
+type pageCursor struct {
+	Field      string             `bson:"f"`
+	Descending bool               `bson:"d"`
+	Value      bson.RawValue      `bson:"v"`
+	ID         primitive.ObjectID `bson:"id"`
+}
+
+// ParseSort reads a sort parameter such as "name" or "-name" (descending) and
+// returns the matching document field. The allowed map translates parameter
+// names to fields; unknown names fall back to the default field.
+func ParseSort(param string, allowed map[string]string, defaultField string, defaultDescending bool) (string, bool) {
+	if param == "" {
+		return defaultField, defaultDescending
+	}
+
+	descending := strings.HasPrefix(param, "-")
+	field, ok := allowed[strings.TrimPrefix(param, "-")]
+	if !ok {
+		return defaultField, defaultDescending
+	}
+	return field, descending
+}
+
+// findPage runs a cursor paginated query, sorting by the query's field and _id
+func findPage[T any](mongo *db.MongoRepo, collection string, filter bson.M, query ListQuery) (Page[T], error) {
+	page := Page[T]{Items: []T{}}
+
+	total, err := mongo.CountDocuments(collection, filter)
+	if err != nil {
+		return page, err
+	}
+	page.Total = total
+
+	direction := 1
+	comparison := "$gt"
+	if query.Descending {
+		direction = -1
+		comparison = "$lt"
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query)
+		if err != nil {
+			return page, err
+		}
+		filter = bson.M{"$and": []bson.M{filter, {
+			"$or": []bson.M{
+				{query.SortField: bson.M{comparison: cursor.Value}},
+				{query.SortField: cursor.Value, "_id": bson.M{comparison: cursor.ID}},
+			},
+		}}}
+	}
+
+	// Fetch one extra document to know whether there is a next page
+	opts := options.Find().
+		SetSort(bson.D{{Key: query.SortField, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(query.Limit + 1))
+
+	var docs []bson.Raw
+	if err := mongo.FindAllWithOptions(collection, filter, &docs, opts); err != nil {
+		return page, err
+	}
+
+	if len(docs) > query.Limit {
+		docs = docs[:query.Limit]
+		last := docs[len(docs)-1]
+		page.NextCursor, err = encodeCursor(query, last.Lookup(query.SortField), last.Lookup("_id").ObjectID())
+		if err != nil {
+			return page, err
+		}
+	}
+
+	for _, doc := range docs {
+		var item T
+		if err := bson.Unmarshal(doc, &item); err != nil {
+			return page, err
+		}
+		page.Items = append(page.Items, item)
+	}
+
+	return page, nil
+}
+
+// eachDocument streams the documents matching the filter sorted by the query's
+// field, decoding each one before passing it to fn. Limit and cursor are ignored.
+func eachDocument[T any](mongo *db.MongoRepo, collection string, filter bson.M, query ListQuery, fn func(T) error) error {
+	direction := 1
+	if query.Descending {
+		direction = -1
+	}
+	opts := options.Find().SetSort(bson.D{{Key: query.SortField, Value: direction}, {Key: "_id", Value: direction}})
+
+	return mongo.FindEach(collection, filter, opts, func(doc bson.Raw) error {
+		var item T
+		if err := bson.Unmarshal(doc, &item); err != nil {
+			return err
+		}
+		return fn(item)
+	})
+}
+
+func encodeCursor(query ListQuery, value bson.RawValue, id primitive.ObjectID) (string, error) {
+	data, err := bson.Marshal(pageCursor{Field: query.SortField, Descending: query.Descending, Value: value, ID: id})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor reads the cursor of the query, which must have been returned by a
+// listing sorted the same way
+func decodeCursor(query ListQuery) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor pageCursor
+	if err := bson.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Field != query.SortField || cursor.Descending != query.Descending {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
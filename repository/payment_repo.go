@@ -10,8 +10,27 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// PaymentFilter holds the optional filters of the payment listing
+type PaymentFilter struct {
+	UserID           primitive.ObjectID   // Owner whose payments are listed
+	ExcludeClientIDs []primitive.ObjectID // Clients whose payments are left out, e.g. the ones in the trash
+	Status           models.PaymentStatus
+	DateFrom         *time.Time // Payment date range
+	DateTo           *time.Time
+	AmountMin        *float64
+	AmountMax        *float64
+}
+
+// PaymentSortFields maps the sort parameters of the payment listing to document fields
+var PaymentSortFields = map[string]string{
+	"payment_date": "payment_date",
+	"amount":       "amount",
+	"created_at":   "created_at",
+}
+
 type PaymentRepository struct {
 	Mongo *db.MongoRepo
 	cache cache.Cache
@@ -41,14 +60,69 @@ func (r *PaymentRepository) CreatePayment(payment *models.Payment) error {
 			fmt.Sprintf("payment:%s", payment.ID.Hex()),
 			fmt.Sprintf("payments:client:%s", payment.ClientID.Hex()),
 			"payments:all")
+		InvalidateStats(context.Background(), r.cache, payment.UserID)
+	}
+
+	return nil
+}
+
+// ImportPayments stores already completed payments of a user, such as payments
+// made before the owner started using the API, and moves the last payment date
+// and paid through date of their clients forward when the payments are newer.
+// record then runs with the stored payments, to post them to the ledger.
+// Everything is written in a single transaction.
+func (r *PaymentRepository) ImportPayments(userID primitive.ObjectID, payments []*models.Payment, record func(ctx mongo.SessionContext) error) error {
+	docs := make([]any, len(payments))
+	for i, payment := range payments {
+		payment.Status = models.PaymentStatusCompleted
+		docs[i] = payment
+	}
+
+	err := r.Mongo.WithTransaction(func(ctx mongo.SessionContext) error {
+		ids, err := r.Mongo.InsertMany(ctx, "payments", docs)
+		if err != nil {
+			return err
+		}
+
+		for i, payment := range payments {
+			payment.ID = ids[i].(primitive.ObjectID)
+
+			// $max keeps the client's dates when they are already later
+			update := bson.M{
+				"$max": bson.M{
+					"last_payment_date": payment.PaymentDate,
+					"paid_through":      payment.PaidThrough(payment.PaymentDate.Location()),
+				},
+				"$set": bson.M{"updated_at": time.Now()},
+			}
+			if err := r.Mongo.UpdateOneWithContext(ctx, "clients", bson.M{"_id": payment.ClientID}, update); err != nil {
+				return err
+			}
+		}
+		return record(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invalidar caché si está disponible
+	if r.cache != nil {
+		keys := []string{"payments:all", cache.GenerateKey("clients", userID.Hex())}
+		for _, payment := range payments {
+			keys = append(keys,
+				fmt.Sprintf("payments:client:%s", payment.ClientID.Hex()),
+				cache.GenerateKey("client", payment.ClientID.Hex()))
+		}
+		cache.InvalidateCache(context.Background(), r.cache, keys...)
+		InvalidateStats(context.Background(), r.cache, userID)
 	}
 
 	return nil
 }
 
 // CompletePayment updates a payment to completed state
-func (r *PaymentRepository) CompletePayment(paymentID primitive.ObjectID) error {
-	filter := bson.M{"_id": paymentID}
+func (r *PaymentRepository) CompletePayment(payment *models.Payment) error {
+	filter := bson.M{"_id": payment.ID}
 	update := bson.M{
 		"$set": bson.M{
 			"status":     models.PaymentStatusCompleted,
@@ -64,15 +138,17 @@ func (r *PaymentRepository) CompletePayment(paymentID primitive.ObjectID) error
 	// Invalidar caché si está disponible
 	if r.cache != nil {
 		cache.InvalidateCache(context.Background(), r.cache,
-			fmt.Sprintf("payment:%s", paymentID.Hex()))
+			fmt.Sprintf("payment:%s", payment.ID.Hex()),
+			fmt.Sprintf("payments:client:%s", payment.ClientID.Hex()))
+		InvalidateStats(context.Background(), r.cache, payment.UserID)
 	}
 
 	return nil
 }
 
 // RejectPayment updates a payment to rejected state with an error message
-func (r *PaymentRepository) RejectPayment(paymentID primitive.ObjectID, errorMsg string) error {
-	filter := bson.M{"_id": paymentID}
+func (r *PaymentRepository) RejectPayment(payment *models.Payment, errorMsg string) error {
+	filter := bson.M{"_id": payment.ID}
 	update := bson.M{
 		"$set": bson.M{
 			"status":     models.PaymentStatusRejected,
@@ -89,7 +165,9 @@ func (r *PaymentRepository) RejectPayment(paymentID primitive.ObjectID, errorMsg
 	// Invalidar caché si está disponible
 	if r.cache != nil {
 		cache.InvalidateCache(context.Background(), r.cache,
-			fmt.Sprintf("payment:%s", paymentID.Hex()))
+			fmt.Sprintf("payment:%s", payment.ID.Hex()),
+			fmt.Sprintf("payments:client:%s", payment.ClientID.Hex()))
+		InvalidateStats(context.Background(), r.cache, payment.UserID)
 	}
 
 	return nil
@@ -167,3 +245,50 @@ func (r *PaymentRepository) GetByID(id primitive.ObjectID) (*models.Payment, err
 	}
 	return &payment, nil
 }
+
+// List returns a page of the payments matching the filter
+func (r *PaymentRepository) List(filter PaymentFilter, query ListQuery) (Page[models.Payment], error) {
+	return findPage[models.Payment](r.Mongo, "payments", paymentConditions(filter), query)
+}
+
+// Each streams every payment matching the filter, in the query's order
+func (r *PaymentRepository) Each(filter PaymentFilter, query ListQuery, fn func(models.Payment) error) error {
+	return eachDocument(r.Mongo, "payments", paymentConditions(filter), query, fn)
+}
+
+// paymentConditions builds the Mongo filter of the payment listing
+func paymentConditions(filter PaymentFilter) bson.M {
+	conditions := bson.M{"user_id": filter.UserID}
+
+	if len(filter.ExcludeClientIDs) > 0 {
+		conditions["client_id"] = bson.M{"$nin": filter.ExcludeClientIDs}
+	}
+
+	if filter.Status != "" {
+		conditions["status"] = filter.Status
+	}
+
+	date := bson.M{}
+	if filter.DateFrom != nil {
+		date["$gte"] = *filter.DateFrom
+	}
+	if filter.DateTo != nil {
+		date["$lte"] = *filter.DateTo
+	}
+	if len(date) > 0 {
+		conditions["payment_date"] = date
+	}
+
+	amount := bson.M{}
+	if filter.AmountMin != nil {
+		amount["$gte"] = *filter.AmountMin
+	}
+	if filter.AmountMax != nil {
+		amount["$lte"] = *filter.AmountMax
+	}
+	if len(amount) > 0 {
+		conditions["amount"] = amount
+	}
+
+	return conditions
+}
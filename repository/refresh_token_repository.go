@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrRefreshTokenNotFound is returned for refresh tokens that were never issued,
+	// were revoked or have expired
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is
+	// presented again, after its family has been revoked
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+type RefreshTokenRepository struct {
+	Mongo *db.MongoRepo
+}
+
+func NewRefreshTokenRepository(mongo *db.MongoRepo) *RefreshTokenRepository {
+	return &RefreshTokenRepository{Mongo: mongo}
+}
+
+func (r *RefreshTokenRepository) Create(token *models.RefreshToken) error {
+	result, err := r.Mongo.Create("refresh_tokens", token)
+	if err != nil {
+		return err
+	}
+	token.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// Consume marks a refresh token as used so it can't be rotated twice. Presenting
+// a token that was already used revokes every token of its family.
+func (r *RefreshTokenRepository) Consume(jti string) (*models.RefreshToken, error) {
+	now := time.Now()
+	filter := bson.M{
+		"jti":        jti,
+		"used_at":    bson.M{"$exists": false},
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": now},
+	}
+	matched, err := r.Mongo.UpdateOneMatched("refresh_tokens", filter, bson.M{"$set": bson.M{"used_at": now}})
+	if err != nil {
+		return nil, err
+	}
+
+	var token models.RefreshToken
+	if _, err := r.Mongo.FindOne("refresh_tokens", bson.M{"jti": jti}, &token); err != nil {
+		return nil, ErrRefreshTokenNotFound
+	}
+	if matched == 1 {
+		return &token, nil
+	}
+
+	if token.UsedAt != nil {
+		if err := r.RevokeFamily(token.Family); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	return nil, ErrRefreshTokenNotFound
+}
+
+// RevokeFamily revokes every token rotated from the same login
+func (r *RefreshTokenRepository) RevokeFamily(family string) error {
+	return r.revoke(bson.M{"family": family})
+}
+
+// RevokeAllForUser revokes the refresh tokens of every session of a user
+func (r *RefreshTokenRepository) RevokeAllForUser(userID primitive.ObjectID) error {
+	return r.revoke(bson.M{"user_id": userID})
+}
+
+func (r *RefreshTokenRepository) revoke(filter bson.M) error {
+	filter["revoked_at"] = bson.M{"$exists": false}
+	_, err := r.Mongo.UpdateMany("refresh_tokens", filter, bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	return err
+}
@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github/Rubncal04/youtube-premium/cache"
+	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type StatsRepository struct {
+	Mongo *db.MongoRepo
+	cache cache.Cache
+}
+
+func NewStatsRepository(mongo *db.MongoRepo, cache cache.Cache) *StatsRepository {
+	return &StatsRepository{
+		Mongo: mongo,
+		cache: cache,
+	}
+}
+
+// GetStats computes the dashboard statistics of an owner between from and to.
+// price is the owner's monthly price, used to compute the expected amount.
+// Results are cached until the owner's payments or clients change.
+func (r *StatsRepository) GetStats(userID primitive.ObjectID, from, to time.Time, price float64) (*models.Stats, error) {
+	fetch := func() (models.Stats, error) {
+		return r.computeStats(userID, from, to, price)
+	}
+
+	if r.cache != nil {
+		ctx := context.Background()
+		key := cache.GenerateKey("stats", userID.Hex(), statsGeneration(ctx, r.cache, userID), from.Unix(), to.Unix(), price)
+		var stats models.Stats
+		result, err := cache.WithCache(ctx, r.cache, key, stats, 1*time.Hour, fetch)
+		if err != nil {
+			return nil, err
+		}
+		return &result, nil
+	}
+
+	stats, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (r *StatsRepository) computeStats(userID primitive.ObjectID, from, to time.Time, price float64) (models.Stats, error) {
+	stats := models.Stats{
+		From: from.Format(time.RFC3339),
+		To:   to.Format(time.RFC3339),
+	}
+
+	if err := r.collectPayments(&stats, userID, from, to); err != nil {
+		return stats, err
+	}
+	if err := r.countClients(&stats, userID, time.Now().Day()); err != nil {
+		return stats, err
+	}
+
+	months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month()) + 1
+	stats.Expected = float64(stats.BillableClients) * price * float64(months)
+
+	series, err := r.monthlyRevenue(userID, to)
+	if err != nil {
+		return stats, err
+	}
+	stats.MonthlyRevenue = series
+
+	return stats, nil
+}
+
+// collectPayments sums the completed payments in the range and how many days
+// after the client's due date they were made
+func (r *StatsRepository) collectPayments(stats *models.Stats, userID primitive.ObjectID, from, to time.Time) error {
+	timezone := mongoTimezone(to)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"user_id":      userID,
+			"status":       models.PaymentStatusCompleted,
+			"payment_date": bson.M{"$gte": from, "$lte": to},
+		}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "clients",
+			"localField":   "client_id",
+			"foreignField": "_id",
+			"as":           "client",
+		}}},
+		{{Key: "$unwind", Value: "$client"}},
+		{{Key: "$addFields", Value: bson.M{
+			"year":  bson.M{"$year": bson.M{"date": "$payment_date", "timezone": timezone}},
+			"month": bson.M{"$month": bson.M{"date": "$payment_date", "timezone": timezone}},
+		}}},
+		// Day 0 of the next month is the last day of the payment's month
+		{{Key: "$addFields", Value: bson.M{
+			"last_day": bson.M{"$dayOfMonth": bson.M{"timezone": timezone, "date": bson.M{"$dateFromParts": bson.M{
+				"year": "$year", "month": bson.M{"$add": bson.A{"$month", 1}}, "day": 0, "timezone": timezone,
+			}}}},
+		}}},
+		{{Key: "$addFields", Value: bson.M{
+			"due_date": bson.M{"$dateFromParts": bson.M{
+				"year": "$year", "month": "$month", "day": bson.M{"$min": bson.A{"$client.day_to_pay", "$last_day"}},
+				"timezone": timezone,
+			}},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   nil,
+			"total": bson.M{"$sum": "$amount"},
+			"count": bson.M{"$sum": 1},
+			"days_late": bson.M{"$avg": bson.M{"$max": bson.A{0, bson.M{
+				"$divide": bson.A{bson.M{"$subtract": bson.A{"$payment_date", "$due_date"}}, 24 * 60 * 60 * 1000},
+			}}}},
+		}}},
+	}
+
+	var results []struct {
+		Total    float64 `bson:"total"`
+		Count    int64   `bson:"count"`
+		DaysLate float64 `bson:"days_late"`
+	}
+	if err := r.Mongo.Aggregate("payments", pipeline, &results); err != nil {
+		return err
+	}
+
+	if len(results) > 0 {
+		stats.Collected = results[0].Total
+		stats.PaymentsCount = results[0].Count
+		stats.AverageDaysLate = results[0].DaysLate
+	}
+	return nil
+}
+
+// countClients counts the owner's billable clients by payment situation
+func (r *StatsRepository) countClients(stats *models.Stats, userID primitive.ObjectID, today int) error {
+	owing := bson.M{"$in": bson.A{"$status", bson.A{models.ClientStatusPending, models.ClientStatusGrace, models.ClientStatusInactive}}}
+	countIf := func(condition any) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{condition, 1, 0}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: NotDeleted(bson.M{"user_id": userID})}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      nil,
+			"paid":     countIf(bson.M{"$eq": bson.A{"$status", models.ClientStatusActive}}),
+			"unpaid":   countIf(bson.M{"$and": bson.A{owing, bson.M{"$gte": bson.A{"$day_to_pay", today}}}}),
+			"overdue":  countIf(bson.M{"$and": bson.A{owing, bson.M{"$lt": bson.A{"$day_to_pay", today}}}}),
+			"billable": countIf(bson.M{"$or": bson.A{owing, bson.M{"$eq": bson.A{"$status", models.ClientStatusActive}}}}),
+		}}},
+	}
+
+	var results []struct {
+		Paid     int64 `bson:"paid"`
+		Unpaid   int64 `bson:"unpaid"`
+		Overdue  int64 `bson:"overdue"`
+		Billable int64 `bson:"billable"`
+	}
+	if err := r.Mongo.Aggregate("clients", pipeline, &results); err != nil {
+		return err
+	}
+
+	if len(results) > 0 {
+		stats.PaidClients = results[0].Paid
+		stats.UnpaidClients = results[0].Unpaid
+		stats.OverdueClients = results[0].Overdue
+		stats.BillableClients = results[0].Billable
+	}
+	return nil
+}
+
+// monthlyRevenue returns the completed payments of the 12 months ending with the month of to
+func (r *StatsRepository) monthlyRevenue(userID primitive.ObjectID, to time.Time) ([]models.MonthlyAmount, error) {
+	end := time.Date(to.Year(), to.Month()+1, 1, 0, 0, 0, 0, to.Location())
+	start := end.AddDate(0, -12, 0)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"user_id":      userID,
+			"status":       models.PaymentStatusCompleted,
+			"payment_date": bson.M{"$gte": start, "$lt": end},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"$dateToString": bson.M{
+				"format": "%Y-%m", "date": "$payment_date", "timezone": mongoTimezone(to),
+			}},
+			"amount": bson.M{"$sum": "$amount"},
+		}}},
+	}
+
+	var results []struct {
+		Period string  `bson:"_id"`
+		Amount float64 `bson:"amount"`
+	}
+	if err := r.Mongo.Aggregate("payments", pipeline, &results); err != nil {
+		return nil, err
+	}
+
+	amounts := make(map[string]float64, len(results))
+	for _, result := range results {
+		amounts[result.Period] = result.Amount
+	}
+
+	// Months without payments are included with 0
+	series := make([]models.MonthlyAmount, 0, 12)
+	for month := start; month.Before(end); month = month.AddDate(0, 1, 0) {
+		period := models.BillingPeriod(month)
+		series = append(series, models.MonthlyAmount{Period: period, Amount: amounts[period]})
+	}
+	return series, nil
+}
+
+// mongoTimezone returns the timezone of t for date operators, which compute in
+// UTC unless told otherwise. The local zone has no name MongoDB knows, so its
+// offset is used instead.
+func mongoTimezone(t time.Time) string {
+	name := t.Location().String()
+	if name == "Local" || name == "" {
+		return t.Format("-07:00")
+	}
+	return name
+}
+
+// statsGeneration returns the current generation of an owner's cached stats.
+// Cached stats are keyed by generation so changing it invalidates all of them.
+func statsGeneration(ctx context.Context, c cache.Cache, userID primitive.ObjectID) string {
+	var generation string
+	if err := c.Get(ctx, cache.GenerateKey("stats:generation", userID.Hex()), &generation); err != nil {
+		return "0"
+	}
+	return generation
+}
+
+// InvalidateStats discards the cached stats of an owner, to be called when its
+// payments or clients change
+func InvalidateStats(ctx context.Context, c cache.Cache, userID primitive.ObjectID) {
+	if c == nil {
+		return
+	}
+
+	generation := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := c.Set(ctx, cache.GenerateKey("stats:generation", userID.Hex()), generation, 0); err != nil {
+		fmt.Printf("Error invalidating stats cache: %v\n", err)
+	}
+}
@@ -3,6 +3,8 @@ package repository
 
 import (
 	"fmt"
+	"time"
+
 	"github/Rubncal04/youtube-premium/db"
 	"github/Rubncal04/youtube-premium/models"
 
@@ -20,13 +22,22 @@ func NewUserRepository(mongo *db.MongoRepo) *UserRepository {
 
 func (r *UserRepository) GetAllUsers() ([]models.User, error) {
 	var users []models.User
-	err := r.Mongo.FindAll("users", "", &users)
+	err := r.Mongo.FindAll("users", bson.M{}, &users)
 	if err != nil {
 		return nil, err
 	}
 	return users, nil
 }
 
+func (r *UserRepository) GetUserByID(userID primitive.ObjectID) (*models.User, error) {
+	var user models.User
+	_, err := r.Mongo.FindOne("users", bson.M{"_id": userID}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *UserRepository) CreateUser(user *models.User) (models.User, error) {
 	coll, err := r.Mongo.Create("users", user)
 	newUser := models.User{
@@ -56,3 +67,98 @@ func (r *UserRepository) UpdateUser(userID string, updateData bson.M) error {
 
 	return r.Mongo.UpdateOne("users", filter, update)
 }
+
+// SetRole changes the role of a user
+func (r *UserRepository) SetRole(userID primitive.ObjectID, role string) error {
+	update := bson.M{"$set": bson.M{"role": role, "updated_at": time.Now()}}
+	return r.Mongo.UpdateOne("users", bson.M{"_id": userID}, update)
+}
+
+// SetDisabled disables or enables the account of a user
+func (r *UserRepository) SetDisabled(userID primitive.ObjectID, disabled bool) error {
+	update := bson.M{"$set": bson.M{"disabled": disabled, "updated_at": time.Now()}}
+	return r.Mongo.UpdateOne("users", bson.M{"_id": userID}, update)
+}
+
+// GetUserByEmail returns the user registered with an email
+func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
+	var user models.User
+	_, err := r.Mongo.FindOne("users", bson.M{"email": email}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// MarkEmailVerified records that a user proved they own their email
+func (r *UserRepository) MarkEmailVerified(userID primitive.ObjectID) error {
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"email_verified": true, "email_verified_at": now, "updated_at": now}}
+	return r.Mongo.UpdateOne("users", bson.M{"_id": userID, "email_verified": bson.M{"$ne": true}}, update)
+}
+
+// SetPassword replaces the password hash of a user
+func (r *UserRepository) SetPassword(userID primitive.ObjectID, passwordHash string) error {
+	update := bson.M{"$set": bson.M{"password": passwordHash, "updated_at": time.Now()}}
+	return r.Mongo.UpdateOne("users", bson.M{"_id": userID}, update)
+}
+
+// SetPendingTOTPSecret stores the secret of a two-factor enrollment until a code confirms it
+func (r *UserRepository) SetPendingTOTPSecret(userID primitive.ObjectID, secret string) error {
+	update := bson.M{"$set": bson.M{"totp_pending_secret": secret, "updated_at": time.Now()}}
+	return r.Mongo.UpdateOne("users", bson.M{"_id": userID}, update)
+}
+
+// EnableTwoFactor turns on two-factor authentication with the pending secret
+// confirmed by the code of the given time step
+func (r *UserRepository) EnableTwoFactor(userID primitive.ObjectID, secret string, step int64, recoveryCodeHashes []string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"two_factor_enabled": true,
+			"totp_secret":        secret,
+			"totp_last_step":     step,
+			"recovery_codes":     recoveryCodeHashes,
+			"updated_at":         time.Now(),
+		},
+		"$unset": bson.M{"totp_pending_secret": ""},
+	}
+	return r.Mongo.UpdateOne("users", bson.M{"_id": userID}, update)
+}
+
+// DisableTwoFactor turns off two-factor authentication and forgets its secret
+// and recovery codes
+func (r *UserRepository) DisableTwoFactor(userID primitive.ObjectID) error {
+	update := bson.M{
+		"$set":   bson.M{"two_factor_enabled": false, "updated_at": time.Now()},
+		"$unset": bson.M{"totp_secret": "", "totp_pending_secret": "", "totp_last_step": "", "recovery_codes": ""},
+	}
+	return r.Mongo.UpdateOne("users", bson.M{"_id": userID}, update)
+}
+
+// SetRecoveryCodes replaces the recovery codes of a user
+func (r *UserRepository) SetRecoveryCodes(userID primitive.ObjectID, recoveryCodeHashes []string) error {
+	update := bson.M{"$set": bson.M{"recovery_codes": recoveryCodeHashes, "updated_at": time.Now()}}
+	return r.Mongo.UpdateOne("users", bson.M{"_id": userID}, update)
+}
+
+// UseTOTPStep records the time step of a TOTP code. It reports false when a code
+// of that step or a later one was already used, so each code works once.
+func (r *UserRepository) UseTOTPStep(userID primitive.ObjectID, step int64) (bool, error) {
+	filter := bson.M{
+		"_id": userID,
+		"$or": bson.A{
+			bson.M{"totp_last_step": bson.M{"$exists": false}},
+			bson.M{"totp_last_step": bson.M{"$lt": step}},
+		},
+	}
+	matched, err := r.Mongo.UpdateOneMatched("users", filter, bson.M{"$set": bson.M{"totp_last_step": step}})
+	return matched == 1, err
+}
+
+// UseRecoveryCode removes a recovery code of a user. It reports false when the
+// user has no such code.
+func (r *UserRepository) UseRecoveryCode(userID primitive.ObjectID, codeHash string) (bool, error) {
+	filter := bson.M{"_id": userID, "recovery_codes": codeHash}
+	matched, err := r.Mongo.UpdateOneMatched("users", filter, bson.M{"$pull": bson.M{"recovery_codes": codeHash}})
+	return matched == 1, err
+}
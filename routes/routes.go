@@ -1,58 +1,202 @@
 package routes
 
 import (
+	"github/Rubncal04/youtube-premium/auth"
+	"github/Rubncal04/youtube-premium/authz"
+	"github/Rubncal04/youtube-premium/billing"
 	"github/Rubncal04/youtube-premium/cache"
 	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/docs"
 	"github/Rubncal04/youtube-premium/handlers"
 	"github/Rubncal04/youtube-premium/middleware"
+	"github/Rubncal04/youtube-premium/notifications"
+	"github/Rubncal04/youtube-premium/ratelimit"
 	"github/Rubncal04/youtube-premium/repository"
 
 	"github.com/labstack/echo/v4"
 )
 
 // RegisterRoutes define las rutas principales de la aplicación.
-func RegisterRoutes(e *echo.Echo, mongoRepo *db.MongoRepo, redisCache *cache.RedisCache, secretKey string) {
+func RegisterRoutes(e *echo.Echo, mongoRepo *db.MongoRepo, redisCache *cache.RedisCache, notifier notifications.NotificationService, mailer notifications.EmailSender, appURL string, keys *auth.KeySet, limits ratelimit.Config) {
+	// A nil *RedisCache stored in a cache.Cache would not compare equal to nil,
+	// so the interface is only set when Redis is available
+	var appCache cache.Cache
+	if redisCache != nil {
+		appCache = redisCache
+	}
+
+	tokenRepo := repository.NewRefreshTokenRepository(mongoRepo)
+	denylist := auth.NewDenylist(appCache)
+	userRepo := repository.NewUserRepository(mongoRepo)
+
+	// Rate limits, counted in the shared cache
+	limiter := ratelimit.NewLimiter(appCache)
+	loginGuard := ratelimit.NewLoginGuard(limiter, limits.Login, limits.Lockout)
+	accountHandler := handlers.NewAccountHandler(userRepo, repository.NewAccountTokenRepository(mongoRepo), tokenRepo, denylist, keys, mailer, appURL, loginGuard)
+	authLimit := middleware.RateLimit(limiter, "auth", limits.Auth, middleware.RateLimitByIP)
+	twoFactorHandler := handlers.NewTwoFactorHandler(userRepo, tokenRepo, keys, denylist, loginGuard)
+
 	// Public routes
 	e.POST("/register", func(c echo.Context) error {
-		return handlers.Register(c, mongoRepo)
-	})
+		return handlers.Register(c, mongoRepo, accountHandler)
+	}, authLimit)
 	e.POST("/login", func(c echo.Context) error {
-		return handlers.Login(c, mongoRepo, secretKey)
-	})
+		return handlers.Login(c, mongoRepo, tokenRepo, keys, loginGuard)
+	}, authLimit)
 	e.POST("/refresh", func(c echo.Context) error {
-		return handlers.RefreshToken(c, secretKey)
+		return handlers.RefreshToken(c, mongoRepo, tokenRepo, keys)
+	}, authLimit)
+	e.GET("/.well-known/jwks.json", func(c echo.Context) error {
+		return handlers.JWKS(c, keys)
 	})
+	e.POST("/logout", func(c echo.Context) error {
+		return handlers.Logout(c, tokenRepo, denylist, keys)
+	}, authLimit)
+	e.POST("/login/2fa", twoFactorHandler.VerifyLogin, authLimit)
+	e.POST("/verify-email", accountHandler.VerifyEmail, authLimit)
+	e.POST("/password/forgot", accountHandler.ForgotPassword, authLimit)
+	e.POST("/password/reset", accountHandler.ResetPassword, authLimit)
+
+	// API documentation
+	docs.Register(e)
 
 	// Protected routes
 	api := e.Group("/api/v1")
-	api.Use(middleware.AuthMiddleware(secretKey))
+	apiKeyRepo := repository.NewAPIKeyRepository(mongoRepo)
+	api.Use(middleware.AuthMiddleware(keys, denylist, apiKeyRepo.Authenticate))
+	api.Use(middleware.RateLimit(limiter, "api", limits.API, middleware.RateLimitByUser))
 
 	// Initialize repositories
 	clientRepo := repository.NewClientRepository(mongoRepo, redisCache)
-	paymentRepo := repository.NewPaymentRepository(mongoRepo, redisCache)
+	paymentRepo := repository.NewPaymentRepository(mongoRepo, appCache)
 	priceConfigRepo := repository.NewPriceConfigurationRepository(mongoRepo, redisCache)
+	ledgerRepo := repository.NewLedgerRepository(mongoRepo)
+	discountRepo := repository.NewDiscountRepository(mongoRepo)
+	lateFeePolicyRepo := repository.NewLateFeePolicyRepository(mongoRepo)
+	statsRepo := repository.NewStatsRepository(mongoRepo, appCache)
+	collaboratorRepo := repository.NewCollaboratorRepository(mongoRepo)
+	authzService := authz.NewService(collaboratorRepo)
+	billingService := billing.NewService(ledgerRepo, discountRepo, priceConfigRepo, clientRepo)
 
 	// Initialize handlers
-	clientHandler := handlers.NewClientHandler(clientRepo)
-	paymentHandler := handlers.NewPaymentHandler(paymentRepo, clientRepo)
+	clientHandler := handlers.NewClientHandler(clientRepo, ledgerRepo, authzService)
+	clientBulkHandler := handlers.NewClientBulkHandler(clientRepo, ledgerRepo, notifier)
+	paymentHandler := handlers.NewPaymentHandler(paymentRepo, clientRepo, authzService, billingService)
 	priceConfigHandler := handlers.NewPriceConfigurationHandler(priceConfigRepo)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerRepo, clientRepo)
+	discountHandler := handlers.NewDiscountHandler(discountRepo, clientRepo, ledgerRepo, billingService)
+	lateFeeHandler := handlers.NewLateFeeHandler(lateFeePolicyRepo, ledgerRepo, clientRepo)
+	exportHandler := handlers.NewExportHandler(clientRepo, paymentRepo, ledgerRepo)
+	importHandler := handlers.NewImportHandler(clientRepo, paymentRepo, billingService)
+	statsHandler := handlers.NewStatsHandler(statsRepo, priceConfigRepo)
+	userHandler := handlers.NewUserHandler(userRepo, tokenRepo, denylist)
+	collaboratorHandler := handlers.NewCollaboratorHandler(collaboratorRepo, userRepo, mailer, appURL)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyRepo)
+
+	// Permissions checked per route, see auth.HasPermission
+	read := middleware.RequirePermission(auth.PermissionRead)
+	write := middleware.RequirePermission(auth.PermissionWrite)
+	manageUsers := middleware.RequirePermission(auth.PermissionManageUsers)
+	// Account and security routes take a login, API keys are rejected
+	session := middleware.RequireSession
+
+	// Clients of the :id and :clientId parameters, loaded and authorized once
+	// before the handler runs, see authz.Service
+	readClient := middleware.LoadClient("id", clientRepo.GetByID, authzService, authz.ActionRead)
+	manageClient := middleware.LoadClient("id", clientRepo.GetByID, authzService, authz.ActionManage)
+	manageDeletedClient := middleware.LoadClient("id", clientRepo.GetDeletedByID, authzService, authz.ActionManage)
+	readPaymentsClient := middleware.LoadClient("clientId", clientRepo.GetByID, authzService, authz.ActionRead)
+	managePaymentsClient := middleware.LoadClient("clientId", clientRepo.GetByID, authzService, authz.ActionManagePayments)
+
+	// Admin routes
+	api.GET("/admin/users", userHandler.GetUsers, manageUsers)
+	api.PUT("/admin/users/:id/role", userHandler.UpdateRole, manageUsers)
+	api.POST("/admin/users/:id/disable", userHandler.DisableUser, manageUsers)
+	api.POST("/admin/users/:id/enable", userHandler.EnableUser, manageUsers)
+
+	// Session routes
+	api.POST("/logout/all", func(c echo.Context) error {
+		return handlers.LogoutAll(c, tokenRepo, denylist)
+	}, session)
+
+	api.PUT("/me/password", func(c echo.Context) error {
+		return handlers.ChangePassword(c, mongoRepo, tokenRepo, denylist)
+	}, session)
+	api.POST("/me/verification", accountHandler.ResendVerification, session)
+
+	// Two-factor authentication routes
+	api.POST("/me/2fa/enroll", twoFactorHandler.Enroll, session)
+	api.POST("/me/2fa/confirm", twoFactorHandler.Confirm, session)
+	api.POST("/me/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes, session)
+	api.POST("/me/2fa/disable", twoFactorHandler.Disable, session)
+
+	// API key routes
+	api.POST("/api-keys", apiKeyHandler.CreateAPIKey, session)
+	api.GET("/api-keys", apiKeyHandler.GetAPIKeys, session)
+	api.DELETE("/api-keys/:id", apiKeyHandler.RevokeAPIKey, session)
+
+	// Collaborator routes
+	api.POST("/collaborators/invitations", collaboratorHandler.CreateInvitation, write)
+	api.GET("/collaborators/invitations", collaboratorHandler.GetInvitations, read)
+	api.DELETE("/collaborators/invitations/:id", collaboratorHandler.DeleteInvitation, write)
+	api.GET("/collaborators", collaboratorHandler.GetCollaborators, read)
+	api.PUT("/collaborators/:id", collaboratorHandler.UpdateCollaborator, write)
+	api.DELETE("/collaborators/:id", collaboratorHandler.DeleteCollaborator, write)
+	api.POST("/invitations/accept", collaboratorHandler.AcceptInvitation, session)
+	api.GET("/shared", collaboratorHandler.GetSharedOwners)
 
 	// Price Configuration routes
-	api.POST("/price-configuration", priceConfigHandler.CreatePriceConfig)
-	api.GET("/price-configuration", priceConfigHandler.GetPriceConfig)
-	api.PUT("/price-configuration", priceConfigHandler.UpdatePriceConfig)
-	api.DELETE("/price-configuration", priceConfigHandler.DeletePriceConfig)
+	api.POST("/price-configuration", priceConfigHandler.CreatePriceConfig, write)
+	api.GET("/price-configuration", priceConfigHandler.GetPriceConfig, read)
+	api.PUT("/price-configuration", priceConfigHandler.UpdatePriceConfig, write)
+	api.DELETE("/price-configuration", priceConfigHandler.DeletePriceConfig, write)
 
 	// Payment routes - specific routes first
-	api.GET("/clients/:clientId/payments/:id", paymentHandler.GetOnePayment)
-	api.GET("/clients/:clientId/payments", paymentHandler.GetPaymentsByClient)
-	api.POST("/clients/:clientId/payments", paymentHandler.CreatePayment)
-	api.GET("/payments", paymentHandler.GetAllPayments)
+	api.GET("/clients/:clientId/payments/:id", paymentHandler.GetOnePayment, read, readPaymentsClient)
+	api.GET("/clients/:clientId/payments", paymentHandler.GetPaymentsByClient, read, readPaymentsClient)
+	api.POST("/clients/:clientId/payments", paymentHandler.CreatePayment, write, managePaymentsClient)
+	api.GET("/payments", paymentHandler.GetAllPayments, read)
+	api.GET("/payments/export", exportHandler.ExportPayments, read)
+	api.POST("/payments/import", importHandler.ImportPayments, write)
+
+	// Ledger routes
+	api.GET("/clients/:id/ledger", ledgerHandler.GetLedger, read, readClient)
+	api.POST("/clients/:id/ledger", ledgerHandler.CreateLedgerEntry, write, manageClient)
+	api.GET("/clients/:id/ledger/export", exportHandler.ExportLedger, read, readClient)
+
+	// Discount routes
+	api.POST("/discounts", discountHandler.CreateDiscount, write)
+	api.GET("/discounts", discountHandler.GetDiscounts, read)
+	api.DELETE("/discounts/:id", discountHandler.DeleteDiscount, write)
+	api.GET("/clients/:id/amount-due", discountHandler.GetAmountDue, read, readClient)
+
+	// Late fee routes
+	api.GET("/late-fee-policy", lateFeeHandler.GetPolicy, read)
+	api.PUT("/late-fee-policy", lateFeeHandler.SavePolicy, write)
+	api.DELETE("/late-fee-policy", lateFeeHandler.DeletePolicy, write)
+	api.POST("/clients/:id/late-fees/:entryId/reverse", lateFeeHandler.ReverseLateFee, write, manageClient)
+
+	// Stats routes
+	api.GET("/stats", statsHandler.GetStats, read)
 
 	// Client routes
-	api.POST("/clients", clientHandler.CreateClient)
-	api.GET("/clients", clientHandler.GetClients)
-	api.GET("/clients/:id", clientHandler.GetClient)
-	api.PUT("/clients/:id", clientHandler.UpdateClient)
-	api.DELETE("/clients/:id", clientHandler.DeleteClient)
+	api.POST("/clients", clientHandler.CreateClient, write)
+	api.GET("/clients", clientHandler.GetClients, read)
+	api.GET("/clients/trash", clientHandler.GetDeletedClients, read)
+	api.GET("/clients/export", exportHandler.ExportClients, read)
+	api.POST("/clients/import", importHandler.ImportClients, write)
+	api.POST("/clients/bulk/update", clientBulkHandler.BulkUpdate, write)
+	api.POST("/clients/bulk/status", clientBulkHandler.BulkChangeStatus, write)
+	api.POST("/clients/bulk/message", clientBulkHandler.BulkMessage, write)
+	api.POST("/clients/bulk/delete", clientBulkHandler.BulkDelete, write)
+	api.GET("/clients/:id", clientHandler.GetClient, read, readClient)
+	api.PUT("/clients/:id", clientHandler.UpdateClient, write, manageClient)
+	api.PATCH("/clients/:id", clientHandler.PatchClient, write, manageClient)
+	api.DELETE("/clients/:id", clientHandler.DeleteClient, write, manageClient)
+	api.POST("/clients/:id/pause", clientHandler.PauseClient, write, manageClient)
+	api.POST("/clients/:id/resume", clientHandler.ResumeClient, write, manageClient)
+	api.POST("/clients/:id/cancel", clientHandler.CancelClient, write, manageClient)
+	api.POST("/clients/:id/archive", clientHandler.ArchiveClient, write, manageClient)
+	api.POST("/clients/:id/restore", clientHandler.RestoreClient, write, manageDeletedClient)
+	api.GET("/clients/:id/status-history", clientHandler.GetStatusHistory, read, readClient)
 }
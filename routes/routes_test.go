@@ -0,0 +1,67 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github/Rubncal04/youtube-premium/auth"
+	"github/Rubncal04/youtube-premium/docs"
+	"github/Rubncal04/youtube-premium/ratelimit"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	e := echo.New()
+	RegisterRoutes(e, nil, nil, nil, nil, "", testKeys(t), ratelimit.Config{})
+
+	for _, route := range e.Routes() {
+		// Groups with middleware register a catch-all route for unknown paths
+		if route.Method == echo.RouteNotFound || route.Path == "/openapi.json" || route.Path == "/docs" {
+			continue
+		}
+		if !docs.HasOperation(route.Method, route.Path) {
+			t.Errorf("%s %s is missing from the OpenAPI document", route.Method, route.Path)
+		}
+	}
+}
+
+func TestEveryOperationHasARoute(t *testing.T) {
+	e := echo.New()
+	RegisterRoutes(e, nil, nil, nil, nil, "", testKeys(t), ratelimit.Config{})
+
+	registered := map[string]bool{}
+	for _, route := range e.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+	for _, op := range docs.Routes() {
+		if !registered[op.Method+" "+op.Path] {
+			t.Errorf("%s %s is documented but has no route", op.Method, op.Path)
+		}
+	}
+}
+
+func TestOpenAPIDocumentIsServed(t *testing.T) {
+	e := echo.New()
+	RegisterRoutes(e, nil, nil, nil, nil, "", testKeys(t), ratelimit.Config{})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json returned %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"openapi":"3.0.3"`) {
+		t.Errorf("unexpected body: %.200s", rec.Body.String())
+	}
+}
+
+func testKeys(t *testing.T) *auth.KeySet {
+	keys, err := auth.NewKeySet(nil, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+
+	"github/Rubncal04/youtube-premium/cache"
+	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DefaultClientRetention is how long deleted clients stay in the trash before being purged
+const DefaultClientRetention = 30 * 24 * time.Hour
+
+// PurgeDeletedClients permanently removes the clients that have been in the trash
+// longer than the retention period, together with their payments and ledger
+func PurgeDeletedClients(mongoRepo *db.MongoRepo, redisCache *cache.RedisCache, retention time.Duration) error {
+	clientRepo := repository.NewClientRepository(mongoRepo, redisCache)
+
+	var clients []models.Client
+	filter := bson.M{"deleted_at": bson.M{"$lte": time.Now().Add(-retention)}}
+	if err := mongoRepo.FindAll("clients", filter, &clients); err != nil {
+		return err
+	}
+
+	purged := 0
+	for _, client := range clients {
+		if err := clientRepo.Purge(client); err != nil {
+			log.Printf("Error purging client %s: %v", client.ID.Hex(), err)
+			continue
+		}
+		purged++
+	}
+
+	log.Printf("Purged %d deleted clients", purged)
+	return nil
+}
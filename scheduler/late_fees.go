@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/notifications"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ApplyLateFees charges a late fee to clients that still owe money once the reminder
+// window plus the owner's configured delay has passed, and notifies them about it.
+// Fees repeat every AfterDays days while the client stays unpaid, up to the cap of
+// the owner's policy for the billing period.
+func ApplyLateFees(mongoRepo *db.MongoRepo, notifier notifications.NotificationService) error {
+	now := time.Now()
+	ledgerRepo := repository.NewLedgerRepository(mongoRepo)
+
+	var clients []models.Client
+	if err := mongoRepo.FindAll("clients", repository.NotDeleted(bson.M{}), &clients); err != nil {
+		return err
+	}
+
+	policies := make(map[string]*models.LateFeePolicy)
+	for _, client := range clients {
+		// The fee belongs to the latest period that is due, which is the previous
+		// month's until this month's payment day comes
+		due := lastDueDate(client.DayToPay, now)
+		if !client.Status.IsBillable() || client.IsPaidFor(due) {
+			continue
+		}
+		period := models.BillingPeriod(due)
+
+		ownerID := client.UserID.Hex()
+		policy, ok := policies[ownerID]
+		if !ok {
+			policy = findLateFeePolicy(mongoRepo, client)
+			policies[ownerID] = policy
+		}
+		if policy == nil {
+			continue
+		}
+
+		threshold := due.AddDate(0, 0, ReminderWindowDays+policy.AfterDays)
+		if now.Before(threshold) {
+			continue
+		}
+
+		balance, err := ledgerRepo.GetBalance(client.ID)
+		if err != nil {
+			log.Printf("Error getting balance for client %s: %v", client.Name, err)
+			continue
+		}
+		if balance >= 0 {
+			continue
+		}
+
+		fees, err := ledgerRepo.GetPeriodEntries(client.ID, period, models.LedgerKindLateFee)
+		if err != nil {
+			log.Printf("Error getting late fees for client %s: %v", client.Name, err)
+			continue
+		}
+
+		// Reversed fees still count, so a reversal is not undone by the next run
+		var charged float64
+		for _, fee := range fees {
+			charged += fee.Amount
+		}
+		if len(fees) > 0 && now.Sub(fees[len(fees)-1].CreatedAt) < time.Duration(policy.Interval())*24*time.Hour {
+			continue
+		}
+
+		amount := policy.FeeFor(-balance, charged)
+		if amount <= 0 {
+			continue
+		}
+
+		entry, err := ledgerRepo.RecordLateFee(client, period, amount)
+		if err != nil {
+			log.Printf("Error charging late fee to client %s: %v", client.Name, err)
+			continue
+		}
+
+		log.Printf("Late fee of %.2f charged to client %s", amount, client.Name)
+
+		message := fmt.Sprintf("Hola, se aplicó un recargo por mora de $%.2f a tu cuenta de YouTube Premium. Total adeudado: $%.2f.",
+			amount, -entry.Balance)
+		if err := notifier.SendReminder(client, message); err != nil {
+			log.Printf("Error sending late fee notice to client %s: %v", client.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// lastDueDate returns the latest payment date for the given payment day that is
+// not after now
+func lastDueDate(dayToPay int, now time.Time) time.Time {
+	due := dueDate(dayToPay, now)
+	if due.After(now) {
+		previousMonth := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, now.Location())
+		due = dueDate(dayToPay, previousMonth)
+	}
+	return due
+}
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github/Rubncal04/youtube-premium/billing"
+	"github/Rubncal04/youtube-premium/cache"
+	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/models"
+	"github/Rubncal04/youtube-premium/repository"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ChargeCatchUpDays is how far back the charge job looks for due dates it missed,
+// so a day the job didn't run doesn't leave a period without its charge
+const ChargeCatchUpDays = 31
+
+// PostPeriodCharges posts the monthly charge of every client whose payment day
+// has come, including the ones missed in the last ChargeCatchUpDays, using the
+// owner's price configuration and the client's discounts. Credit left over from
+// previous payments is applied automatically because the charge is subtracted
+// from the running balance. Status changes go through the cache so the API
+// serves them right away.
+func PostPeriodCharges(mongoRepo *db.MongoRepo, redisCache *cache.RedisCache) error {
+	now := time.Now()
+	ledgerRepo := repository.NewLedgerRepository(mongoRepo)
+	clientRepo := repository.NewClientRepository(mongoRepo, redisCache)
+	billingService := billing.NewService(
+		ledgerRepo,
+		repository.NewDiscountRepository(mongoRepo),
+		repository.NewPriceConfigurationRepository(mongoRepo, redisCache),
+		clientRepo,
+	)
+
+	var clients []models.Client
+	if err := mongoRepo.FindAll("clients", repository.NotDeleted(bson.M{}), &clients); err != nil {
+		return err
+	}
+
+	for _, client := range clients {
+		if !client.Status.IsBillable() {
+			continue
+		}
+		periods := periodsDue(client, now)
+		if len(periods) == 0 {
+			continue
+		}
+
+		charged, err := billingService.ChargePeriods(client, periods)
+		if errors.Is(err, billing.ErrNoPrice) {
+			log.Printf("No price configuration for user %s, skipping charges", client.UserID.Hex())
+			continue
+		}
+		if err != nil {
+			log.Printf("Error posting charges for client %s: %v", client.Name, err)
+		}
+		if charged == 0 {
+			continue // Already charged for these periods
+		}
+
+		balance, err := ledgerRepo.GetBalance(client.ID)
+		if err != nil {
+			log.Printf("Error getting balance for client %s: %v", client.Name, err)
+			continue
+		}
+		if err := clientRepo.SyncStatusWithBalance(&client, balance); err != nil {
+			log.Printf("Error updating status for client %s: %v", client.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// periodsDue returns the billing periods of the client whose due date is between
+// ChargeCatchUpDays ago and now, oldest first. Periods due before the client was
+// created are left out. Periods already charged are skipped when posting.
+func periodsDue(client models.Client, now time.Time) []string {
+	since := now.AddDate(0, 0, -ChargeCatchUpDays)
+	created := client.CreatedAt.In(now.Location())
+	created = time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, now.Location())
+
+	var periods []string
+	month := time.Date(since.Year(), since.Month(), 1, 0, 0, 0, 0, now.Location())
+	for ; !month.After(now); month = month.AddDate(0, 1, 0) {
+		due := dueDate(client.DayToPay, month)
+		if due.After(now) || due.Before(since) || due.Before(created) {
+			continue
+		}
+		periods = append(periods, models.BillingPeriod(month))
+	}
+	return periods
+}
+
+// dueDate returns the payment date of the month of now for the given payment day.
+// Payment days beyond the end of a short month fall on its last day.
+func dueDate(dayToPay int, now time.Time) time.Time {
+	lastDay := time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, now.Location()).Day()
+	if dayToPay > lastDay {
+		dayToPay = lastDay
+	}
+	return time.Date(now.Year(), now.Month(), dayToPay, 0, 0, 0, 0, now.Location())
+}
@@ -2,15 +2,20 @@
 package scheduler
 
 import (
+	"fmt"
 	"github/Rubncal04/youtube-premium/db"
 	"github/Rubncal04/youtube-premium/models"
 	"github/Rubncal04/youtube-premium/notifications"
+	"github/Rubncal04/youtube-premium/repository"
 	"log"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// ReminderWindowDays es la cantidad de días, contando el día de pago, durante los que se envían recordatorios.
+const ReminderWindowDays = 5
+
 // SendPaymentReminders consulta los usuarios y, si es el día de pago (o dentro de la ventana de 5 días)
 // y no han pagado, envía un recordatorio usando el servicio de notificaciones proporcionado.
 func SendPaymentReminders(mongoRepo *db.MongoRepo, notifier notifications.NotificationService) {
@@ -19,18 +24,41 @@ func SendPaymentReminders(mongoRepo *db.MongoRepo, notifier notifications.Notifi
 
 	var clients []models.Client
 	// Traer solo usuarios que no han pagado.
-	err := mongoRepo.FindAll("clients", bson.M{"status": "inactive"}, &clients)
+	filter := repository.NotDeleted(bson.M{"status": bson.M{"$in": []models.ClientStatus{
+		models.ClientStatusPending,
+		models.ClientStatusGrace,
+		models.ClientStatusInactive,
+	}}})
+	err := mongoRepo.FindAll("clients", filter, &clients)
 	if err != nil {
 		log.Printf("Error retrieving clients: %v", err)
 		return
 	}
 
+	policies := make(map[string]*models.LateFeePolicy)
 	for _, client := range clients {
+		// Clientes que pagaron por adelantado este periodo no reciben recordatorio.
+		if client.IsPaidFor(now) {
+			continue
+		}
+
 		dueDay := client.DayToPay
 
 		// Si el día actual está dentro de la ventana de 5 días a partir del día de pago.
-		if currentDay >= dueDay && currentDay <= dueDay+4 {
+		if currentDay >= dueDay && currentDay <= dueDay+ReminderWindowDays-1 {
 			message := "Hola, te recuerdo el compromiso que tienes con YouTube Premium. ¡Quédate al día con tu pago! 😉"
+
+			// Avisar del recargo por mora si el dueño tiene una política activa.
+			ownerID := client.UserID.Hex()
+			policy, ok := policies[ownerID]
+			if !ok {
+				policy = findLateFeePolicy(mongoRepo, client)
+				policies[ownerID] = policy
+			}
+			if policy != nil {
+				message += fmt.Sprintf(" Recuerda que si no pagas, %d días después del último recordatorio se aplicará un recargo de %s.",
+					policy.AfterDays, describeLateFee(policy))
+			}
 			log.Printf("Sending reminder to client %s via Whatsapp...", client.Name)
 
 			// Enviar recordatorio usando el servicio de notificaciones.
@@ -41,3 +69,23 @@ func SendPaymentReminders(mongoRepo *db.MongoRepo, notifier notifications.Notifi
 		}
 	}
 }
+
+// findLateFeePolicy devuelve la política de recargos activa del dueño del cliente, o nil si no tiene.
+func findLateFeePolicy(mongoRepo *db.MongoRepo, client models.Client) *models.LateFeePolicy {
+	var policy models.LateFeePolicy
+	if _, err := mongoRepo.FindOne("late_fee_policies", bson.M{"user_id": client.UserID}, &policy); err != nil {
+		return nil
+	}
+	if !policy.Enabled {
+		return nil
+	}
+	return &policy
+}
+
+// describeLateFee describe el valor del recargo para incluirlo en los mensajes.
+func describeLateFee(policy *models.LateFeePolicy) string {
+	if policy.Type == models.LateFeeTypePercentage {
+		return fmt.Sprintf("%.0f%% de lo adeudado", policy.Value)
+	}
+	return fmt.Sprintf("$%.2f", policy.Value)
+}
@@ -12,10 +12,14 @@ import (
 	"syscall"
 	"time"
 
+	"github/Rubncal04/youtube-premium/auth"
 	"github/Rubncal04/youtube-premium/cache"
 	"github/Rubncal04/youtube-premium/config"
 	"github/Rubncal04/youtube-premium/db"
+	"github/Rubncal04/youtube-premium/handlers"
+	"github/Rubncal04/youtube-premium/migrations"
 	"github/Rubncal04/youtube-premium/notifications"
+	"github/Rubncal04/youtube-premium/ratelimit"
 	"github/Rubncal04/youtube-premium/routes"
 	"github/Rubncal04/youtube-premium/scheduler"
 
@@ -27,21 +31,31 @@ import (
 func StartServer() {
 	// Get environment variables
 	envVariables := config.GetVariables()
-	secretKey := envVariables.JWT_SECRET_KEY
-	if secretKey == "" {
-		log.Fatal("JWT_SECRET_KEY environment variable is required")
+	keys, err := loadKeySet(envVariables)
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	limits, err := loadRateLimits(envVariables)
+	if err != nil {
+		log.Fatalf("Failed to load rate limits: %v", err)
 	}
 
 	// Initialize Echo
 	e := echo.New()
+	e.Validator = handlers.NewRequestValidator()
+	e.HTTPErrorHandler = handlers.HTTPErrorHandler
+	// Rate limits count per client IP, X-Forwarded-For is only trusted when set
+	// by a proxy in a private network so clients can't spoof it
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
 
 	// Middleware
 	e.Use(echoMiddleware.Logger())
 	e.Use(echoMiddleware.Recover())
 	e.Use(echoMiddleware.CORSWithConfig(echoMiddleware.CORSConfig{
-		AllowOrigins: []string{"http://localhost:5173"}, // URL de tu aplicación React
-		AllowMethods: []string{echo.GET, echo.PUT, echo.POST, echo.DELETE},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
+		AllowOrigins:  []string{"http://localhost:5173"}, // URL de tu aplicación React
+		AllowMethods:  []string{echo.GET, echo.PUT, echo.PATCH, echo.POST, echo.DELETE},
+		AllowHeaders:  []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "X-API-Key", "If-Match"},
+		ExposeHeaders: []string{echo.HeaderContentDisposition, "ETag", echo.HeaderRetryAfter, "X-RateLimit-Limit", "X-RateLimit-Remaining"},
 	}))
 
 	// Initialize MongoDB repository
@@ -51,6 +65,11 @@ func StartServer() {
 	}
 	defer mongoRepo.Close()
 
+	// Apply data migrations and create indexes
+	if err := migrations.Run(mongoRepo, adminEmails(envVariables)); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
 	// Initialize Redis cache
 	redisDB, err := strconv.Atoi(envVariables.REDIS_DATABASES)
 	if err != nil {
@@ -63,13 +82,38 @@ func StartServer() {
 		redisCache = nil
 	}
 
+	twilioAccountSID := envVariables.TWILIO_ACCOUNT_SID
+	twilioAuthToken := envVariables.TWILIO_AUTH_TOKEN
+	twilioFromWhatsApp := envVariables.TWILIO_FROM_WHATSAPP
+
+	if twilioAccountSID == "" || twilioAuthToken == "" || twilioFromWhatsApp == "" {
+		log.Fatalf("TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN o TWILIO_FROM_WHATSAPP no están configurados")
+	}
+	twilioService := notifications.NewTwilioService(twilioAccountSID, twilioAuthToken, twilioFromWhatsApp)
+
+	// Emails with verification and password reset links are logged when SMTP
+	// isn't configured, which is enough for development
+	var mailer notifications.EmailSender = notifications.LogEmailSender{}
+	if envVariables.SMTP_HOST != "" {
+		mailer = notifications.NewSMTPService(envVariables.SMTP_HOST, envVariables.SMTP_PORT, envVariables.SMTP_USERNAME, envVariables.SMTP_PASSWORD, envVariables.SMTP_FROM)
+	} else {
+		log.Println("Warning: SMTP_HOST is not configured, emails will be logged instead of sent")
+	}
+	appURL := envVariables.APP_URL
+	if appURL == "" {
+		appURL = "http://localhost:5173"
+	}
+
 	// Root route
 	e.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, "Welcome to YouTube Premium API")
 	})
 
+	// Messages sent from requests are queued so the requests don't wait for Twilio
+	messageQueue := notifications.NewQueue(twilioService, notifications.DefaultQueueSize)
+
 	// Register all routes
-	routes.RegisterRoutes(e, mongoRepo, redisCache, secretKey)
+	routes.RegisterRoutes(e, mongoRepo, redisCache, messageQueue, mailer, appURL, keys, limits)
 
 	// Start server
 	port := envVariables.PORT
@@ -89,15 +133,6 @@ func StartServer() {
 		}
 	}()
 
-	twilioAccountSID := envVariables.TWILIO_ACCOUNT_SID
-	twilioAuthToken := envVariables.TWILIO_AUTH_TOKEN
-	twilioFromWhatsApp := envVariables.TWILIO_FROM_WHATSAPP
-
-	if twilioAccountSID == "" || twilioAuthToken == "" || twilioFromWhatsApp == "" {
-		log.Fatalf("TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN o TWILIO_FROM_WHATSAPP no están configurados")
-	}
-	twilioService := notifications.NewTwilioService(twilioAccountSID, twilioAuthToken, twilioFromWhatsApp)
-
 	loc, err := time.LoadLocation("America/Bogota")
 	if err != nil {
 		log.Fatalf("Error loading location: %v", err)
@@ -105,6 +140,17 @@ func StartServer() {
 
 	c := cron.New(cron.WithLocation(loc))
 
+	// Add period charges task, runs before the reminders so they see updated balances
+	_, err = c.AddFunc("0 6 * * *", func() {
+		log.Println("Posting period charges...")
+		if err := scheduler.PostPeriodCharges(mongoRepo, redisCache); err != nil {
+			log.Printf("Error posting period charges: %v", err)
+		}
+	})
+	if err != nil {
+		log.Fatalf("Error scheduling period charges task: %v", err)
+	}
+
 	// Add payment reminder task
 	_, err = c.AddFunc("0 16 * * *", func() {
 		log.Println("Running payment verification...")
@@ -114,6 +160,32 @@ func StartServer() {
 		log.Fatalf("Error scheduling payment reminder task: %v", err)
 	}
 
+	// Add late fees task, runs after the reminders
+	_, err = c.AddFunc("0 17 * * *", func() {
+		log.Println("Applying late fees...")
+		if err := scheduler.ApplyLateFees(mongoRepo, twilioService); err != nil {
+			log.Printf("Error applying late fees: %v", err)
+		}
+	})
+	if err != nil {
+		log.Fatalf("Error scheduling late fees task: %v", err)
+	}
+
+	// Add purge of deleted clients task
+	clientRetention := scheduler.DefaultClientRetention
+	if days, err := strconv.Atoi(envVariables.CLIENT_RETENTION_DAYS); err == nil && days > 0 {
+		clientRetention = time.Duration(days) * 24 * time.Hour
+	}
+	_, err = c.AddFunc("0 3 * * *", func() {
+		log.Println("Purging deleted clients...")
+		if err := scheduler.PurgeDeletedClients(mongoRepo, redisCache, clientRetention); err != nil {
+			log.Printf("Error purging deleted clients: %v", err)
+		}
+	})
+	if err != nil {
+		log.Fatalf("Error scheduling purge of deleted clients task: %v", err)
+	}
+
 	// Add payment status update tasks
 	// Run on the 13th of each month
 	_, err = c.AddFunc("0 0 13 * *", func() {
@@ -148,4 +220,87 @@ func StartServer() {
 	if err := e.Shutdown(ctx); err != nil {
 		e.Logger.Fatal(err)
 	}
+	if err := messageQueue.Close(ctx); err != nil {
+		log.Printf("Error sending queued messages: %v", err)
+	}
+}
+
+// adminEmails reads the comma separated emails of ADMIN_EMAILS
+func adminEmails(envVariables *config.EnvVariables) []string {
+	var emails []string
+	for _, email := range strings.Split(envVariables.ADMIN_EMAILS, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+// loadKeySet loads the asymmetric keys listed in JWT_KEYS_FILE. JWT_SECRET_KEY
+// keeps verifying legacy HS256 tokens, and signs tokens when no keys are listed.
+func loadKeySet(envVariables *config.EnvVariables) (*auth.KeySet, error) {
+	if envVariables.JWT_KEYS_FILE == "" {
+		if envVariables.JWT_SECRET_KEY == "" {
+			return nil, fmt.Errorf("JWT_KEYS_FILE or JWT_SECRET_KEY environment variable is required")
+		}
+		log.Println("Warning: JWT_KEYS_FILE is not set, signing tokens with HS256")
+		return auth.NewKeySet(nil, envVariables.JWT_SECRET_KEY)
+	}
+	return auth.LoadKeySet(envVariables.JWT_KEYS_FILE, envVariables.JWT_SECRET_KEY)
+}
+
+// loadRateLimits reads the limits configured in the environment, the ones not
+// set keep the defaults of ratelimit.DefaultConfig. A limit of 0 requests
+// disables it.
+func loadRateLimits(envVariables *config.EnvVariables) (ratelimit.Config, error) {
+	limits := ratelimit.DefaultConfig()
+
+	for _, setting := range []struct {
+		name  string
+		value string
+		limit *ratelimit.Limit
+	}{
+		{"RATE_LIMIT_AUTH", envVariables.RATE_LIMIT_AUTH, &limits.Auth},
+		{"RATE_LIMIT_LOGIN", envVariables.RATE_LIMIT_LOGIN, &limits.Login},
+		{"RATE_LIMIT_API", envVariables.RATE_LIMIT_API, &limits.API},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		limit, err := ratelimit.ParseLimit(setting.value)
+		if err != nil {
+			return limits, fmt.Errorf("%s: %w", setting.name, err)
+		}
+		*setting.limit = limit
+	}
+
+	if value := envVariables.LOGIN_LOCKOUT_THRESHOLD; value != "" {
+		threshold, err := strconv.Atoi(value)
+		if err != nil || threshold < 0 {
+			return limits, fmt.Errorf("LOGIN_LOCKOUT_THRESHOLD: invalid number %q", value)
+		}
+		limits.Lockout.Threshold = threshold
+	}
+	for _, setting := range []struct {
+		name     string
+		value    string
+		duration *time.Duration
+	}{
+		{"LOGIN_LOCKOUT_DURATION", envVariables.LOGIN_LOCKOUT_DURATION, &limits.Lockout.Duration},
+		{"LOGIN_LOCKOUT_MAX_DURATION", envVariables.LOGIN_LOCKOUT_MAX_DURATION, &limits.Lockout.MaxDuration},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(setting.value)
+		if err != nil || duration <= 0 {
+			return limits, fmt.Errorf("%s: invalid duration %q", setting.name, setting.value)
+		}
+		*setting.duration = duration
+	}
+	if limits.Lockout.MaxDuration < limits.Lockout.Duration {
+		limits.Lockout.MaxDuration = limits.Lockout.Duration
+	}
+
+	return limits, nil
 }